@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAPIUnauthorized     error = errors.New("Invalid or missing token")
+	ErrAPIMethodNotAllowed error = errors.New("Method not allowed")
+	ErrAPIInvalidPosition  error = errors.New("Position has to be between 2 and the length of the queue")
+)
+
+type APIItem struct {
+	ID          string  `json:"id"`
+	Title       string  `json:"title"`
+	Link        string  `json:"link"`
+	Thumbnail   string  `json:"thumbnail,omitempty"`
+	Duration    float64 `json:"duration"` // seconds
+	RequestedBy string  `json:"requestedBy"`
+	AutoQueued  bool    `json:"autoQueued"`
+}
+
+type APINowPlaying struct {
+	Item     *APIItem `json:"item"`
+	Position float64  `json:"position"` // seconds
+	Paused   bool     `json:"paused"`
+}
+
+type APIEnqueueRequest struct {
+	URL  string `json:"url"`
+	Next bool   `json:"next"` // put it right after the current song
+}
+
+type APIMoveRequest struct {
+	ID string `json:"id"` // ID or position of the item
+	To int    `json:"to"` // 1-indexed, like in !move
+}
+
+type APISeekRequest struct {
+	Position float64 `json:"position"` // seconds
+}
+
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// apiHandler gets the user authenticated by token
+type apiHandler func(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error)
+
+func NewAPIItem(item *QueueItem) *APIItem {
+	return &APIItem{
+		ID:          item.ID,
+		Title:       item.Info.Title,
+		Link:        item.Info.Link,
+		Thumbnail:   item.Info.Thumbnail,
+		Duration:    item.Info.Duration.Seconds(),
+		RequestedBy: item.RequestedBy,
+		AutoQueued:  item.AutoQueued,
+	}
+}
+
+func NewAPINowPlaying(player *Player) APINowPlaying {
+	nowPlaying := APINowPlaying{}
+
+	if player.IsPlaying {
+		song, err := player.Queue.GetFirst()
+		if err == nil {
+			nowPlaying.Item = NewAPIItem(song)
+			nowPlaying.Position = player.CurrentPosition().Seconds()
+			nowPlaying.Paused = player.Paused()
+		}
+	}
+
+	return nowPlaying
+}
+
+// InitAPI registers REST endpoints, every request needs a token from the config, which maps to a discord user whose permissions apply
+func (bot *Bot) InitAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/queue", bot.apiEndpoint(map[string]apiHandler{
+		http.MethodGet:  bot.apiGetQueue,
+		http.MethodPost: bot.apiEnqueue,
+	}))
+	mux.HandleFunc("/api/queue/", bot.apiEndpoint(map[string]apiHandler{
+		http.MethodDelete: bot.apiRemove,
+	}))
+	mux.HandleFunc("/api/queue/move", bot.apiEndpoint(map[string]apiHandler{
+		http.MethodPost: bot.apiMove,
+	}))
+	mux.HandleFunc("/api/nowplaying", bot.apiEndpoint(map[string]apiHandler{
+		http.MethodGet: bot.apiNowPlaying,
+	}))
+	mux.HandleFunc("/api/skip", bot.apiEndpoint(map[string]apiHandler{
+		http.MethodPost: bot.apiSkip,
+	}))
+	mux.HandleFunc("/api/pause", bot.apiEndpoint(map[string]apiHandler{
+		http.MethodPost: bot.apiPause,
+	}))
+	mux.HandleFunc("/api/seek", bot.apiEndpoint(map[string]apiHandler{
+		http.MethodPost: bot.apiSeek,
+	}))
+}
+
+func (bot *Bot) apiEndpoint(handlers map[string]apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler := handlers[r.Method]
+		if handler == nil {
+			writeAPIResponse(w, http.StatusMethodNotAllowed, APIError{ErrAPIMethodNotAllowed.Error()})
+			return
+		}
+
+		user, status, err := bot.apiUser(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil {
+			writeAPIResponse(w, status, APIError{err.Error()})
+			return
+		}
+
+		response, err := handler(user, w, r)
+		if err == ErrPermissionDenied {
+			writeAPIResponse(w, http.StatusForbidden, APIError{err.Error()})
+			return
+		} else if err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, APIError{err.Error()})
+			return
+		}
+
+		if response == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeAPIResponse(w, http.StatusOK, response)
+	}
+}
+
+// apiUser looks up the discord user the token belongs to, the status is meant for the response if it fails
+func (bot *Bot) apiUser(token string) (*discordgo.User, int, error) {
+	userID, ok := bot.Config().APITokens[token]
+	if !ok {
+		return nil, http.StatusUnauthorized, ErrAPIUnauthorized
+	}
+
+	user, err := bot.DiscordSession.User(userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user", userID).Error("Looking up API user failed")
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return user, http.StatusOK, nil
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logrus.WithError(err).Warn("Writing API response failed")
+	}
+}
+
+// apiPlayer checks the user may run the command and returns the player
+func (bot *Bot) apiPlayer(user *discordgo.User, command string) (*Player, error) {
+	if !bot.HasPermission(user.ID, bot.Commands.ByName[command]) {
+		countDenial(bot.Commands.ByName[command])
+		return nil, ErrPermissionDenied
+	}
+
+	if bot.Player == nil {
+		return nil, ErrPlayerNotConnected
+	}
+
+	return bot.Player, nil
+}
+
+// apiUndoable makes a queue change which can be reverted with !undo, like the change made by the command
+func (bot *Bot) apiUndoable(player *Player, command string, change func() error) error {
+	before, _ := player.Queue.GetAll()
+	taken := time.Now()
+
+	err := change()
+
+	player.Undo.Record(bot.Commands.ByName[command], before, taken, &player.Queue)
+
+	return err
+}
+
+func (bot *Bot) apiGetQueue(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	player, err := bot.apiPlayer(user, "playlist")
+	if err != nil {
+		return nil, err
+	}
+
+	queue, _ := player.Queue.GetAll()
+
+	items := make([]*APIItem, len(queue))
+	for i, item := range queue {
+		items[i] = NewAPIItem(item)
+	}
+
+	return items, nil
+}
+
+func (bot *Bot) apiEnqueue(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var request APIEnqueueRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	command := "queue"
+	if request.Next {
+		command = "playnext"
+	}
+
+	player, err := bot.apiPlayer(user, command)
+	if err != nil {
+		return nil, err
+	}
+
+	id := YoutubeVideoRegexp.FindStringSubmatch(request.URL)
+	if len(id) == 0 {
+		return nil, errors.New("No video matched")
+	}
+
+	item, err := CreateQueueItem(id[1], user, bot.Blocklist)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bot.apiUndoable(player, command, func() error {
+		if request.Next {
+			return player.AddNext(item)
+		}
+
+		return player.Add(item)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAPIItem(item), nil
+}
+
+func (bot *Bot) apiRemove(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	player, err := bot.apiPlayer(user, "remove")
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, bot.apiUndoable(player, "remove", func() error {
+		return player.Queue.RemoveRef(strings.TrimPrefix(r.URL.Path, "/api/queue/"))
+	})
+}
+
+func (bot *Bot) apiMove(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var request APIMoveRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	player, err := bot.apiPlayer(user, "move")
+	if err != nil {
+		return nil, err
+	}
+
+	// the current song stays first
+	if request.To < 2 || request.To > player.Queue.Len() {
+		return nil, ErrAPIInvalidPosition
+	}
+
+	return nil, bot.apiUndoable(player, "move", func() error {
+		return player.Queue.MoveRef(request.ID, request.To-1) // slices are 0-index, but appears as 1-indexed to the user
+	})
+}
+
+func (bot *Bot) apiNowPlaying(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	player, err := bot.apiPlayer(user, "info")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAPINowPlaying(player), nil
+}
+
+func (bot *Bot) apiSkip(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	player, err := bot.apiPlayer(user, "skip")
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, player.SendCommand(Stop)
+}
+
+func (bot *Bot) apiPause(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	player, err := bot.apiPlayer(user, "pause")
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, player.SendCommand(Pause)
+}
+
+func (bot *Bot) apiSeek(user *discordgo.User, w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	var request APISeekRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	player, err := bot.apiPlayer(user, "seek")
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, player.Seek(time.Duration(request.Position * float64(time.Second)))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testBot has a player with the given songs queued, everybody has default permissions
+func testBot(ids ...string) *Bot {
+	bot := &Bot{
+		Commands:    CreateCommands(),
+		Permissions: &PermissionsManager{permissions: make(Permissions)},
+		Player:      &Player{Undo: CreateUndoLog(UndoLogSize)},
+	}
+	bot.setConfig(&Configuration{})
+	bot.Commands.InitPlayer()
+	bot.Player.Queue.Add(testItems(ids...)...)
+
+	return bot
+}
+
+func TestAPIMove(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{`{"id":"3","to":2}`, []string{"a", "c", "b"}, false},
+		{`{"id":"c","to":2}`, []string{"a", "c", "b"}, false},
+		{`{"id":"2","to":3}`, []string{"a", "c", "b"}, false},
+		{`{"id":"1","to":3}`, []string{"a", "b", "c"}, true}, // the playing song stays first
+		{`{"id":"a","to":3}`, []string{"a", "b", "c"}, true},
+		{`{"id":"3","to":1}`, []string{"a", "b", "c"}, true},
+		{`{"id":"3","to":4}`, []string{"a", "b", "c"}, true},
+		{`{"id":"zz","to":2}`, []string{"a", "b", "c"}, true},
+		{`not json`, []string{"a", "b", "c"}, true},
+	}
+
+	for _, test := range tests {
+		bot := testBot("a", "b", "c")
+		r := httptest.NewRequest("POST", "/api/queue/move", strings.NewReader(test.body))
+
+		_, err := bot.apiMove(&discordgo.User{ID: "1"}, httptest.NewRecorder(), r)
+		if (err != nil) != test.wantErr {
+			t.Errorf("apiMove(%s) error = %v, want error %v", test.body, err, test.wantErr)
+		}
+		if got := infoIDs(&bot.Player.Queue); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("apiMove(%s) = %v, want %v", test.body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"google.golang.org/api/youtube/v3"
+	"strings"
+)
+
+const (
+	AutoplayRequester       = "Autoplay"
+	AutoplayHistoryAttempts = 5 // random history entries tried before giving up when they're blocked
+)
+
+var ErrNothingToAutoplay error = errors.New("Nothing to autoplay")
+
+// NextAutoplay picks a song to play after the last one when the queue runs dry, related videos are preferred, history is the fallback
+func (player *Player) NextAutoplay(last *QueueItem) (*QueueItem, error) {
+	if player.ClientConfig != nil && strings.HasPrefix(last.Info.ID, "youtube:") {
+		item, err := player.relatedVideo(strings.TrimPrefix(last.Info.ID, "youtube:"))
+		if err == nil {
+			return item, nil
+		}
+
+		player.log().WithError(err).Info("No related video for autoplay, picking from history")
+	}
+
+	var entry HistoryEntry
+	var err error
+	for i := 0; i < AutoplayHistoryAttempts; i++ {
+		entry, err = player.History.Random(last.Info.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = player.Blocklist.CheckItem(entry.Item)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item := *entry.Item
+	item.RequestedBy = AutoplayRequester
+	item.RequesterID = ""
+	item.AutoQueued = true
+
+	return &item, nil
+}
+
+func (player *Player) AutoplayEnabled() bool {
+	player.settingsLock.RLock()
+	defer player.settingsLock.RUnlock()
+
+	return player.Autoplay
+}
+
+// ToggleAutoplay switches autoplay and returns whether it's enabled now
+func (player *Player) ToggleAutoplay() bool {
+	player.settingsLock.Lock()
+	defer player.settingsLock.Unlock()
+
+	player.Autoplay = !player.Autoplay
+
+	return player.Autoplay
+}
+
+func (player *Player) relatedVideo(videoID string) (*QueueItem, error) {
+	service, err := youtube.New(player.ClientConfig.Client(context.Background()))
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := RelatedVideo(service, videoID, &discordgo.User{Username: AutoplayRequester}, player.Blocklist, player.History.Contains)
+	if err != nil {
+		return nil, err
+	}
+
+	item.AutoQueued = true
+
+	return item, nil
+}
+
+func (cmds *Commands) InitAutoplay() {
+	autoplay := CommandConstructor{
+		Names:             []string{"autoplay", "radio"},
+		Permission:        "autoplay",
+		DefaultPermission: true,
+		NoArguments:       true,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			if bot.Player.ToggleAutoplay() {
+				s.ChannelMessageSend(m.ChannelID, "Autoplay enabled")
+			} else {
+				s.ChannelMessageSend(m.ChannelID, "Autoplay disabled")
+			}
+
+			return nil
+		},
+	}
+
+	cmds.RegisterCommands(&autoplay)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/youtube/v3"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type BlockedContent struct {
+	Videos   []string `json:"videos"`   // youtube video IDs
+	Channels []string `json:"channels"` // youtube channel IDs
+	Keywords []string `json:"keywords"` // regular expressions matched against titles, case insensitive
+}
+
+type ErrBlocked struct {
+	title  string
+	reason string
+}
+
+func (err ErrBlocked) Error() string {
+	return fmt.Sprintf("%s is blocked: %s", err.title, err.reason)
+}
+
+// blockedKeyword keeps the compiled expression with its source, keywords failing to compile are only in BlockedContent
+type blockedKeyword struct {
+	source string
+	re     *regexp.Regexp
+}
+
+type Blocklist struct {
+	sync.RWMutex
+	filepath     string
+	blocked      BlockedContent
+	keywords     []blockedKeyword
+	ClientConfig *jwt.Config // needed to look up channels of videos, channels aren't checked without it
+}
+
+func (cmds *Commands) InitBlocklist(filePath string, clientConfig *jwt.Config) *Blocklist {
+	bl := Blocklist{
+		filepath:     filePath,
+		ClientConfig: clientConfig,
+	}
+
+	file, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).Error("Loading blocklist failed")
+	}
+	if file != nil {
+		json.Unmarshal(file, &bl.blocked)
+	}
+
+	for _, keyword := range bl.blocked.Keywords {
+		compiled, err := compileKeyword(keyword)
+		if err != nil {
+			logrus.WithError(err).WithField("keyword", keyword).Error("Invalid blocked keyword")
+			continue
+		}
+
+		bl.keywords = append(bl.keywords, blockedKeyword{keyword, compiled})
+	}
+
+	block := CommandConstructor{
+		Names:             []string{"block"},
+		Permission:        "block",
+		DefaultPermission: false,
+		NoArguments:       false,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if len(raw) == 0 || raw[0] == "" {
+				s.ChannelMessageSend(m.ChannelID, bl.String())
+				return nil
+			}
+
+			if len(raw) < 2 {
+				return errors.New("Usage: !block video|channel|keyword <value>")
+			}
+
+			err := bl.Block(raw[0], strings.Join(raw[1:], " "))
+			if err != nil {
+				return err
+			}
+
+			s.ChannelMessageSend(m.ChannelID, "Blocked!")
+			return nil
+		},
+	}
+
+	unblock := CommandConstructor{
+		Names:             []string{"unblock"},
+		Permission:        "block",
+		DefaultPermission: false,
+		NoArguments:       false,
+		MinArguments:      2,
+		MaxArguments:      -1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			err := bl.Unblock(raw[0], strings.Join(raw[1:], " "))
+			if err != nil {
+				return err
+			}
+
+			s.ChannelMessageSend(m.ChannelID, "Unblocked!")
+			return nil
+		},
+	}
+
+	cmds.RegisterCommands(&block, &unblock)
+
+	return &bl
+}
+
+func compileKeyword(keyword string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + keyword)
+}
+
+func (bl *Blocklist) Save() {
+	bl.RLock()
+	defer bl.RUnlock()
+
+	text, err := json.Marshal(bl.blocked)
+	if err != nil {
+		logrus.WithError(err).Error("Saving blocklist failed")
+		return
+	}
+
+	err = ioutil.WriteFile(bl.filepath, text, 0644)
+	if err != nil {
+		logrus.WithError(err).Error("Saving blocklist failed")
+	}
+}
+
+func (bl *Blocklist) Block(kind string, value string) error {
+	bl.Lock()
+
+	switch kind {
+	case "video":
+		if id := YoutubeVideoRegexp.FindStringSubmatch(value); len(id) > 0 {
+			value = id[1]
+		}
+		bl.blocked.Videos = appendUnique(bl.blocked.Videos, value)
+	case "channel":
+		bl.blocked.Channels = appendUnique(bl.blocked.Channels, value)
+	case "keyword":
+		compiled, err := compileKeyword(value)
+		if err != nil {
+			bl.Unlock()
+			return err
+		}
+		if !containsString(bl.blocked.Keywords, value) {
+			bl.blocked.Keywords = append(bl.blocked.Keywords, value)
+			bl.keywords = append(bl.keywords, blockedKeyword{value, compiled})
+		}
+	default:
+		bl.Unlock()
+		return errors.New("Unknown block type, use video, channel or keyword")
+	}
+
+	bl.Unlock()
+	bl.Save()
+
+	return nil
+}
+
+func (bl *Blocklist) Unblock(kind string, value string) error {
+	bl.Lock()
+
+	var found bool
+	switch kind {
+	case "video":
+		if id := YoutubeVideoRegexp.FindStringSubmatch(value); len(id) > 0 {
+			value = id[1]
+		}
+		bl.blocked.Videos, found = removeString(bl.blocked.Videos, value)
+	case "channel":
+		bl.blocked.Channels, found = removeString(bl.blocked.Channels, value)
+	case "keyword":
+		bl.blocked.Keywords, found = removeString(bl.blocked.Keywords, value)
+
+		for i, keyword := range bl.keywords {
+			if keyword.source == value {
+				bl.keywords = append(bl.keywords[:i], bl.keywords[i+1:]...)
+				break
+			}
+		}
+	default:
+		bl.Unlock()
+		return errors.New("Unknown block type, use video, channel or keyword")
+	}
+
+	bl.Unlock()
+
+	if !found {
+		return errors.New("Not blocked")
+	}
+
+	bl.Save()
+
+	return nil
+}
+
+// CheckYoutube returns ErrBlocked if the video, its channel or its title is blocked
+func (bl *Blocklist) CheckYoutube(video *YoutubeItem) error {
+	return bl.check(video.Video.ID, video.Video.Title)
+}
+
+// CheckItem checks an item which is already known, like one from the history, videos and channels are only checked for youtube items
+func (bl *Blocklist) CheckItem(item *QueueItem) error {
+	var videoID string
+	if strings.HasPrefix(item.Info.ID, "youtube:") {
+		videoID = strings.TrimPrefix(item.Info.ID, "youtube:")
+	}
+
+	return bl.check(videoID, item.Info.Title)
+}
+
+func (bl *Blocklist) check(videoID string, title string) error {
+	if bl == nil {
+		return nil
+	}
+
+	bl.RLock()
+	defer bl.RUnlock()
+
+	for _, id := range bl.blocked.Videos {
+		if videoID != "" && id == videoID {
+			return ErrBlocked{title, "video"}
+		}
+	}
+
+	for _, keyword := range bl.keywords {
+		if keyword.re.MatchString(title) {
+			return ErrBlocked{title, "keyword " + keyword.source}
+		}
+	}
+
+	if videoID != "" && len(bl.blocked.Channels) > 0 && bl.ClientConfig != nil {
+		channel, err := bl.youtubeChannel(videoID)
+		if err != nil {
+			logrus.WithError(err).WithField("video", videoID).Warn("Looking up channel of video failed")
+			return nil
+		}
+
+		for _, id := range bl.blocked.Channels {
+			if id == channel {
+				return ErrBlocked{title, "channel"}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (bl *Blocklist) youtubeChannel(videoID string) (string, error) {
+	service, err := youtube.New(bl.ClientConfig.Client(context.Background()))
+	if err != nil {
+		return "", err
+	}
+
+	videos, err := service.Videos.List("snippet").Id(videoID).Do()
+	if err != nil {
+		return "", err
+	}
+
+	if len(videos.Items) == 0 {
+		return "", errors.New("No video found")
+	}
+
+	return videos.Items[0].Snippet.ChannelId, nil
+}
+
+func (bl *Blocklist) String() string {
+	bl.RLock()
+	defer bl.RUnlock()
+
+	return fmt.Sprintf("Videos: %s\nChannels: %s\nKeywords: %s",
+		strings.Join(bl.blocked.Videos, ", "),
+		strings.Join(bl.blocked.Channels, ", "),
+		strings.Join(bl.blocked.Keywords, ", "))
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func appendUnique(list []string, value string) []string {
+	if containsString(list, value) {
+		return list
+	}
+
+	return append(list, value)
+}
+
+func removeString(list []string, value string) ([]string, bool) {
+	for i, item := range list {
+		if item == value {
+			return append(list[:i], list[i+1:]...), true
+		}
+	}
+
+	return list, false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/bwmarrin/discordgo"
+)
+
+const ThreadNameSize = 100
+
+type ChannelConfig struct {
+	ID             string   `yaml:"id"`
+	Commands       []string `yaml:"commands"`       // optional, command names or permissions allowed here, all commands when empty
+	ReplyInThreads bool     `yaml:"replyInThreads"` // optional, answers go to a thread started on the command
+}
+
+// Allows checks the command against the allowlist, by any of its names or by its permission
+func (cc *ChannelConfig) Allows(cmd *CommandConstructor) bool {
+	if len(cc.Commands) == 0 {
+		return true
+	}
+
+	for _, allowed := range cc.Commands {
+		if allowed == cmd.Permission {
+			return true
+		}
+
+		for _, name := range cmd.Names {
+			if allowed == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Channel returns settings of a channel commands are accepted in, textChannel is accepted even if it isn't listed
+func (config *Configuration) Channel(channelID string) *ChannelConfig {
+	for i := range config.Channels {
+		if config.Channels[i].ID == channelID {
+			return &config.Channels[i]
+		}
+	}
+
+	if channelID == config.TextChannel {
+		return &ChannelConfig{ID: channelID}
+	}
+
+	return nil
+}
+
+// startThread starts a public thread on the message, discordgo has no call for it so the endpoint is requested directly
+func startThread(s *discordgo.Session, channelID string, messageID string, name string) (*discordgo.Channel, error) {
+	if runes := []rune(name); len(runes) > ThreadNameSize {
+		name = string(runes[:ThreadNameSize])
+	}
+
+	data := struct {
+		Name                string `json:"name"`
+		AutoArchiveDuration int    `json:"auto_archive_duration"` // minutes
+	}{name, 60}
+
+	endpoint := discordgo.EndpointChannelMessage(channelID, messageID) + "/threads"
+	body, err := s.RequestWithBucketID("POST", endpoint, data, discordgo.EndpointChannelMessage(channelID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	var thread discordgo.Channel
+	err = json.Unmarshal(body, &thread)
+	if err != nil {
+		return nil, err
+	}
+
+	return &thread, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"github.com/bwmarrin/discordgo"
 	"strings"
 	"time"
@@ -13,9 +14,15 @@ type CommandConstructor struct {
 	NoArguments       bool
 	MinArguments      int
 	MaxArguments      int
+	Undoable          bool // changes of the queue made by the command can be reverted with !undo
 	RunFunc           func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error
 }
 
+var (
+	ErrPermissionDenied error = errors.New("Permission denied!")
+	ErrCommandPanicked  error = errors.New("Something went wrong, see the log")
+)
+
 type NameCommand map[string]*CommandConstructor
 type PermissionCommand map[string]*CommandConstructor
 
@@ -48,7 +55,8 @@ func (bot *Bot) ProcessCommand(s *discordgo.Session, m *discordgo.MessageCreate)
 		return
 	}
 
-	if m.ChannelID != bot.Config.TextChannel || channel.Type != discordgo.ChannelTypeGuildText {
+	settings := bot.Config().Channel(m.ChannelID)
+	if settings == nil || channel.Type != discordgo.ChannelTypeGuildText {
 		return
 	}
 
@@ -65,6 +73,11 @@ func (bot *Bot) ProcessCommand(s *discordgo.Session, m *discordgo.MessageCreate)
 		return
 	}
 
+	if !settings.Allows(cmd) {
+		s.ChannelMessageSend(m.ChannelID, "This command can't be used in this channel")
+		return
+	}
+
 	if len(parsed[1:]) < cmd.MinArguments {
 		s.ChannelMessageSend(m.ChannelID, "Not enough arguments provided")
 		return
@@ -75,23 +88,80 @@ func (bot *Bot) ProcessCommand(s *discordgo.Session, m *discordgo.MessageCreate)
 		return
 	}
 
-	if !bot.Permissions.Get(m.Author.ID, cmd.Permission, cmd.DefaultPermission) && m.Author.ID != bot.Config.Owner {
-		s.ChannelMessageSend(m.ChannelID, "Permission denied!")
+	if !bot.HasPermission(m.Author.ID, cmd) {
+		countDenial(cmd)
+		s.ChannelMessageSend(m.ChannelID, ErrPermissionDenied.Error())
 		return
 	}
 
+	// the command is deleted later from the channel it was sent to, unless it starts a thread
+	commandChannel := m.ChannelID
+	threaded := false
+
+	if settings.ReplyInThreads {
+		thread, err := startThread(s, m.ChannelID, m.ID, m.Content)
+		if err != nil {
+			bot.commandLog(m).WithError(err).Warn("Starting reply thread failed")
+		} else {
+			reply := *m.Message
+			reply.ChannelID = thread.ID
+			m = &discordgo.MessageCreate{Message: &reply}
+			threaded = true
+		}
+	}
+
 	if cmd.NoArguments {
-		err = cmd.RunFunc(bot, nil, m, s)
+		err = bot.RunCommand(cmd, nil, m, s)
 	} else {
-		err = cmd.RunFunc(bot, parsed[1:], m, s)
+		err = bot.RunCommand(cmd, parsed[1:], m, s)
 	}
 
 	if err != nil {
+		bot.commandLog(m).WithError(err).Info("Command failed")
 		s.ChannelMessageSend(m.ChannelID, "Error: "+err.Error())
 	}
 
+	if threaded {
+		return
+	}
+
 	go func() {
 		time.Sleep(5 * time.Second)
-		s.ChannelMessageDelete(bot.Config.TextChannel, m.ID)
+		s.ChannelMessageDelete(commandChannel, m.ID)
+	}()
+}
+
+// RunCommand runs the command, queue changes made by undoable commands are recorded, a panicking command only fails itself
+func (bot *Bot) RunCommand(cmd *CommandConstructor, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			bot.commandLog(m).WithField("panic", r).Error("Command panicked")
+			CommandsTotal.WithLabelValues(cmd.Names[0], "error").Inc()
+			err = ErrCommandPanicked
+		}
 	}()
+
+	player := bot.Player
+	if !cmd.Undoable || player == nil {
+		err = cmd.RunFunc(bot, raw, m, s)
+	} else {
+		before, _ := player.Queue.GetAll()
+		taken := time.Now()
+
+		err = cmd.RunFunc(bot, raw, m, s)
+
+		player.Undo.Record(cmd, before, taken, &player.Queue)
+	}
+
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	CommandsTotal.WithLabelValues(cmd.Names[0], outcome).Inc()
+
+	return err
+}
+
+func (bot *Bot) HasPermission(userID string, cmd *CommandConstructor) bool {
+	return bot.Permissions.Get(userID, cmd.Permission, cmd.DefaultPermission) || userID == bot.Config().Owner
 }
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"fmt"
 	"github.com/jonas747/dca"
+	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
 )
 
+// Configuration is read from config.yml, every setting can be overridden with TANUKI_* environment variables, see applyEnv
 type Configuration struct {
 	//Discord settings
 	Token       string `yaml:"token"`       // required, with Bot prefix
@@ -13,16 +21,182 @@ type Configuration struct {
 	TextChannel string `yaml:"textChannel"` // required, will listen to commands in this channel
 	Owner       string `yaml:"owner"`       // optional, won't let you set permissions and use admin commands
 
+	//Command channels, textChannel is still where the player posts and accepts every command unless it's listed here
+	Channels []ChannelConfig `yaml:"channels"` // optional, more channels to listen to commands in
+
+	//HTTP settings
+	HTTPAddress string            `yaml:"httpAddress"` // optional, e.g. :8080, serves the API, the dashboard at /dashboard, /metrics, /healthz and /readyz, disabled when empty
+	APITokens   map[string]string `yaml:"apiTokens"`   // optional, API token -> discord user ID whose permissions apply
+
+	//Logging settings
+	LogLevel  string `yaml:"logLevel"`  // optional, debug, info, warn or error, defaults to info
+	LogFormat string `yaml:"logFormat"` // optional, text or json, defaults to text
+
 	//Service settings
 	YoutubeAPIKey string `yaml:"ytApiKey"`
 
 	//FFmpeg settings
 	EncodeOptions dca.EncodeOptions `yaml:"encodeOptions"`
+
+	//Player settings
+	HistorySize int  `yaml:"historySize"` // optional, how many played songs to remember, defaults to 50
+	Autoplay    bool `yaml:"autoplay"`    // optional, whether autoplay is enabled when the player joins
+
+	//Queue limits, zero means unlimited, users with bypassLimits permission aren't limited
+	Limits QueueLimits `yaml:"limits"`
+
+	//Loudness normalization settings
+	Normalize      bool    `yaml:"normalize"`      // optional, measures songs on first play and evens out their volume on later plays
+	TargetLoudness float64 `yaml:"targetLoudness"` // optional, in LUFS, defaults to -23 (EBU R128)
 }
 
-func (config *Configuration) Load(configPath string) {
-	configFile, _ := ioutil.ReadFile(configPath)
+const EnvPrefix = "TANUKI_"
+
+type ErrInvalidConfig []string
+
+func (err ErrInvalidConfig) Error() string {
+	return "Invalid config: " + strings.Join(err, ", ")
+}
+
+// Load reads the config file, if there is one, and applies environment overrides, unknown keys in the file are returned
+func (config *Configuration) Load(configPath string) ([]string, error) {
+	var unknown []string
+
+	configFile, err := ioutil.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
 	if configFile != nil {
-		yaml.Unmarshal(configFile, &config)
+		err = yaml.Unmarshal(configFile, config)
+		if err != nil {
+			return nil, err
+		}
+
+		// the file is fine, so the strict decoder can only complain about unknown or duplicate keys
+		if typeErr, ok := yaml.UnmarshalStrict(configFile, &Configuration{}).(*yaml.TypeError); ok {
+			unknown = typeErr.Errors
+		}
+	}
+
+	err = applyEnv(reflect.ValueOf(config).Elem(), EnvPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return unknown, nil
+}
+
+// applyEnv overrides fields with TANUKI_<KEY> variables, e.g. TANUKI_TEXT_CHANNEL or TANUKI_LIMITS_MAX_LENGTH,
+// TANUKI_<KEY>_FILE reads the value from a file instead, meant for secrets
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		name := prefix + envName(key)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+			err := applyEnv(v.Field(i), name+"_")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if path, isFile := os.LookupEnv(name + "_FILE"); isFile {
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			value, ok = strings.TrimSpace(string(content)), true
+		}
+		if !ok {
+			continue
+		}
+
+		// strings are taken as they are, everything else is parsed like in the config file
+		if field.Type.Kind() == reflect.String {
+			v.Field(i).SetString(value)
+		} else if err := yaml.Unmarshal([]byte(value), v.Field(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
 	}
+
+	return nil
+}
+
+// envName turns camelCase into CAMEL_CASE
+func envName(key string) string {
+	var name []rune
+
+	runes := []rune(key)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) {
+			name = append(name, '_')
+		}
+		name = append(name, unicode.ToUpper(r))
+	}
+
+	return string(name)
+}
+
+// Validate reports every missing or invalid setting at once
+func (config *Configuration) Validate() error {
+	var problems ErrInvalidConfig
+
+	if config.Token == "" {
+		problems = append(problems, "token is required")
+	} else if !strings.HasPrefix(config.Token, "Bot ") {
+		problems = append(problems, "token needs the Bot prefix")
+	}
+
+	if config.Guild == "" {
+		problems = append(problems, "guild is required")
+	}
+
+	if config.TextChannel == "" {
+		problems = append(problems, "textChannel is required")
+	}
+
+	seen := make(map[string]bool)
+	for i, channel := range config.Channels {
+		if channel.ID == "" {
+			problems = append(problems, fmt.Sprintf("channels[%d] has no id", i))
+		} else if seen[channel.ID] {
+			problems = append(problems, "channel "+channel.ID+" is listed twice")
+		}
+		seen[channel.ID] = true
+	}
+
+	if config.LogLevel != "" {
+		if _, err := logrus.ParseLevel(config.LogLevel); err != nil {
+			problems = append(problems, "logLevel "+err.Error())
+		}
+	}
+
+	if config.LogFormat != "" && config.LogFormat != "text" && config.LogFormat != "json" {
+		problems = append(problems, "logFormat must be text or json")
+	}
+
+	switch config.Limits.Duplicates {
+	case "", DuplicatesAllow, DuplicatesWarn, DuplicatesReject:
+	default:
+		problems = append(problems, "limits.duplicates must be allow, warn or reject")
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"token", "TOKEN"},
+		{"textChannel", "TEXT_CHANNEL"},
+		{"ytApiKey", "YT_API_KEY"},
+		{"maxPerUser", "MAX_PER_USER"},
+		{"httpAddress", "HTTP_ADDRESS"},
+		{"bitrate", "BITRATE"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := envName(test.key); got != test.want {
+			t.Errorf("envName(%q) = %q, want %q", test.key, got, test.want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "token")
+	err := ioutil.WriteFile(secret, []byte("Bot from-file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		env     map[string]string
+		want    Configuration
+		wantErr bool
+	}{
+		{nil, Configuration{}, false},
+		{map[string]string{"TANUKI_TEXT_CHANNEL": "123"}, Configuration{TextChannel: "123"}, false},
+		{map[string]string{"TANUKI_AUTOPLAY": "true"}, Configuration{Autoplay: true}, false},
+		{map[string]string{"TANUKI_LIMITS_MAX_LENGTH": "50"}, Configuration{Limits: QueueLimits{MaxLength: 50}}, false},
+		{map[string]string{"TANUKI_LIMITS_MAX_DURATION": "15m"}, Configuration{Limits: QueueLimits{MaxDuration: 15 * time.Minute}}, false},
+		{map[string]string{"TANUKI_API_TOKENS": "{secret: \"42\"}"}, Configuration{APITokens: map[string]string{"secret": "42"}}, false},
+		{map[string]string{"TANUKI_TOKEN_FILE": secret}, Configuration{Token: "Bot from-file"}, false},
+		{map[string]string{"TANUKI_TOKEN": "Bot env", "TANUKI_TOKEN_FILE": secret}, Configuration{Token: "Bot from-file"}, false},
+		{map[string]string{"TANUKI_LIMITS_MAX_LENGTH": "lots"}, Configuration{}, true},
+		{map[string]string{"TANUKI_TOKEN_FILE": secret + ".missing"}, Configuration{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(keys(test.env), ","), func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			var config Configuration
+			err := applyEnv(reflect.ValueOf(&config).Elem(), EnvPrefix)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("applyEnv() error = %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(config, test.want) {
+				t.Errorf("applyEnv() = %+v, want %+v", config, test.want)
+			}
+		})
+	}
+}
+
+func keys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func TestValidate(t *testing.T) {
+	valid := Configuration{Token: "Bot abc", Guild: "1", TextChannel: "2"}
+
+	tests := []struct {
+		modify   func(config *Configuration)
+		problems int
+	}{
+		{func(config *Configuration) {}, 0},
+		{func(config *Configuration) { config.Token = "" }, 1},
+		{func(config *Configuration) { config.Token = "abc" }, 1},
+		{func(config *Configuration) { *config = Configuration{} }, 3},
+		{func(config *Configuration) { config.Channels = []ChannelConfig{{ID: "3"}, {ID: "4"}} }, 0},
+		{func(config *Configuration) { config.Channels = []ChannelConfig{{ID: "3"}, {ID: "3"}} }, 1},
+		{func(config *Configuration) { config.Channels = []ChannelConfig{{Commands: []string{"queue"}}} }, 1},
+		{func(config *Configuration) { config.LogLevel, config.LogFormat = "debug", "json" }, 0},
+		{func(config *Configuration) { config.LogLevel, config.LogFormat = "loud", "xml" }, 2},
+		{func(config *Configuration) { config.Limits.Duplicates = DuplicatesReject }, 0},
+		{func(config *Configuration) { config.Limits.Duplicates = "sometimes" }, 1},
+	}
+
+	for i, test := range tests {
+		config := valid
+		test.modify(&config)
+
+		err := config.Validate()
+		if test.problems == 0 {
+			if err != nil {
+				t.Errorf("case %d: Validate() = %v, want nil", i, err)
+			}
+			continue
+		}
+
+		problems, ok := err.(ErrInvalidConfig)
+		if !ok || len(problems) != test.problems {
+			t.Errorf("case %d: Validate() = %v, want %d problems", i, err, test.problems)
+		}
+	}
+}
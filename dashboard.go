@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DashboardSendBuffer   = 8
+	DashboardWriteTimeout = 10 * time.Second
+)
+
+// DashboardState is sent to every connected browser whenever the player or the queue changes
+type DashboardState struct {
+	NowPlaying APINowPlaying `json:"nowPlaying"`
+	Queue      []*APIItem    `json:"queue"`
+}
+
+// Dashboard serves the web UI and keeps its websocket clients up to date
+type Dashboard struct {
+	sync.Mutex
+	bot      *Bot
+	clients  map[*dashboardClient]bool
+	state    []byte // last broadcast, sent to clients right after they connect
+	updates  chan bool
+	upgrader websocket.Upgrader
+}
+
+type dashboardClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// InitDashboard registers the web UI and its websocket, it uses the same tokens as the REST API
+func (bot *Bot) InitDashboard(mux *http.ServeMux) *Dashboard {
+	d := Dashboard{
+		bot:     bot,
+		clients: make(map[*dashboardClient]bool),
+		updates: make(chan bool, 1),
+	}
+
+	mux.HandleFunc("/dashboard", d.serveDashboard)
+	mux.HandleFunc("/api/ws", d.serveWebsocket)
+
+	go d.run()
+	d.Update()
+
+	bot.Events.Subscribe(func(event Event) {
+		d.Update()
+	})
+
+	return &d
+}
+
+// Update schedules a broadcast of the current state, bursts of events end up as a single one
+func (d *Dashboard) Update() {
+	select {
+	case d.updates <- true:
+	default: // an update is pending already
+	}
+}
+
+// run builds the state in its own goroutine, getting the position needs the player to answer
+func (d *Dashboard) run() {
+	for range d.updates {
+		state, err := json.Marshal(d.currentState())
+		if err != nil {
+			logrus.WithError(err).Error("Encoding dashboard state failed")
+			continue
+		}
+
+		d.Lock()
+		d.state = state
+		for client := range d.clients {
+			select {
+			case client.send <- state:
+			default:
+				// client can't keep up, it reconnects and gets a fresh state
+				d.remove(client)
+			}
+		}
+		d.Unlock()
+	}
+}
+
+func (d *Dashboard) currentState() DashboardState {
+	state := DashboardState{Queue: []*APIItem{}}
+
+	player := d.bot.Player
+	if player == nil {
+		return state
+	}
+
+	state.NowPlaying = NewAPINowPlaying(player)
+
+	queue, _ := player.Queue.GetAll()
+	for _, item := range queue {
+		state.Queue = append(state.Queue, NewAPIItem(item))
+	}
+
+	return state
+}
+
+func (d *Dashboard) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardHTML))
+}
+
+// serveWebsocket takes the token as a query parameter, browsers can't set headers on websockets
+func (d *Dashboard) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	user, status, err := d.bot.apiUser(r.URL.Query().Get("token"))
+	if err != nil {
+		writeAPIResponse(w, status, APIError{err.Error()})
+		return
+	}
+
+	if !d.bot.HasPermission(user.ID, d.bot.Commands.ByName["playlist"]) {
+		countDenial(d.bot.Commands.ByName["playlist"])
+		writeAPIResponse(w, http.StatusForbidden, APIError{ErrPermissionDenied.Error()})
+		return
+	}
+
+	conn, err := d.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.WithError(err).Warn("Dashboard websocket upgrade failed")
+		return
+	}
+
+	client := &dashboardClient{
+		conn: conn,
+		send: make(chan []byte, DashboardSendBuffer),
+	}
+
+	d.Lock()
+	d.clients[client] = true
+	if d.state != nil {
+		client.send <- d.state
+	}
+	d.Unlock()
+
+	go client.write()
+
+	// nothing is expected from the browser, reading only notices when it goes away
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	d.Lock()
+	d.remove(client)
+	d.Unlock()
+}
+
+// remove has to be called with the lock held
+func (d *Dashboard) remove(client *dashboardClient) {
+	if d.clients[client] {
+		delete(d.clients, client)
+		close(client.send)
+	}
+}
+
+func (client *dashboardClient) write() {
+	defer client.conn.Close()
+
+	for message := range client.send {
+		client.conn.SetWriteDeadline(time.Now().Add(DashboardWriteTimeout))
+
+		err := client.conn.WriteMessage(websocket.TextMessage, message)
+		if err != nil {
+			return
+		}
+	}
+
+	client.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Tanuki</title>
+<style>
+body { font-family: sans-serif; max-width: 800px; margin: 2em auto; background: #2c2f33; color: #eee; }
+a { color: #e67e22; }
+#login, #main { display: none; }
+#progress { height: 6px; background: #555; margin: .5em 0; }
+#bar { height: 100%; width: 0; background: #e67e22; }
+#queue { list-style: none; padding: 0; }
+#queue li { padding: .5em; margin: 2px 0; background: #23272a; cursor: grab; }
+#queue li.playing { cursor: default; border-left: 3px solid #e67e22; }
+#queue li.over { outline: 1px dashed #e67e22; }
+.meta { color: #999; font-size: .85em; }
+#status { color: #999; }
+</style>
+</head>
+<body>
+<div id="login">
+	<input id="token" type="password" placeholder="API token">
+	<button onclick="login()">Connect</button>
+</div>
+<div id="main">
+	<h2 id="title">Nothing is playing</h2>
+	<div id="progress"><div id="bar"></div></div>
+	<div class="meta"><span id="time"></span> <span id="paused"></span></div>
+	<h3>Queue</h3>
+	<ol id="queue"></ol>
+	<p id="status"></p>
+</div>
+<script>
+var state = null, received = 0, dragged = null;
+
+function token() { return localStorage.getItem("tanukiToken"); }
+
+function login() {
+	localStorage.setItem("tanukiToken", document.getElementById("token").value);
+	connect();
+}
+
+function format(seconds) {
+	seconds = Math.max(0, Math.floor(seconds));
+	var s = seconds % 60, m = Math.floor(seconds / 60) % 60, h = Math.floor(seconds / 3600);
+	return (h > 0 ? h + ":" + (m < 10 ? "0" : "") : "") + m + ":" + (s < 10 ? "0" : "") + s;
+}
+
+function connect() {
+	if (!token()) {
+		document.getElementById("login").style.display = "block";
+		return;
+	}
+	document.getElementById("login").style.display = "none";
+	document.getElementById("main").style.display = "block";
+
+	var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+	var ws = new WebSocket(scheme + location.host + "/api/ws?token=" + encodeURIComponent(token()));
+	var opened = false;
+	ws.onopen = function() {
+		opened = true;
+		document.getElementById("status").textContent = "";
+	};
+	ws.onmessage = function(e) {
+		state = JSON.parse(e.data);
+		received = Date.now();
+		render();
+	};
+	ws.onclose = function(e) {
+		if (!opened) {
+			// most likely a wrong token
+			localStorage.removeItem("tanukiToken");
+			document.getElementById("main").style.display = "none";
+			connect();
+			return;
+		}
+		document.getElementById("status").textContent = "Disconnected, retrying...";
+		setTimeout(connect, 3000);
+	};
+}
+
+function render() {
+	var np = state.nowPlaying;
+	var title = document.getElementById("title");
+	if (np.item) {
+		title.innerHTML = "";
+		var link = document.createElement("a");
+		link.href = np.item.link;
+		link.textContent = np.item.title;
+		title.appendChild(link);
+	} else {
+		title.textContent = "Nothing is playing";
+	}
+	document.getElementById("paused").textContent = np.paused ? "(paused)" : "";
+
+	var queue = document.getElementById("queue");
+	queue.innerHTML = "";
+	state.queue.forEach(function(item, i) {
+		var li = document.createElement("li");
+		var link = document.createElement("a");
+		link.href = item.link;
+		link.textContent = item.title;
+		var meta = document.createElement("div");
+		meta.className = "meta";
+		meta.textContent = "#" + item.id + " • " + format(item.duration) + " • " + item.requestedBy + (item.autoQueued ? " [autoplay]" : "");
+		li.appendChild(link);
+		li.appendChild(meta);
+
+		if (i === 0 && np.item) {
+			li.className = "playing";
+		} else {
+			li.draggable = true;
+			li.ondragstart = function() { dragged = item.id; };
+			li.ondragover = function(e) { e.preventDefault(); li.classList.add("over"); };
+			li.ondragleave = function() { li.classList.remove("over"); };
+			li.ondrop = function(e) {
+				e.preventDefault();
+				li.classList.remove("over");
+				if (dragged !== null && dragged !== item.id) {
+					move(dragged, i + 1);
+				}
+				dragged = null;
+			};
+		}
+		queue.appendChild(li);
+	});
+
+	tick();
+}
+
+function tick() {
+	if (!state || !state.nowPlaying.item) {
+		document.getElementById("bar").style.width = "0";
+		document.getElementById("time").textContent = "";
+		return;
+	}
+
+	var np = state.nowPlaying;
+	var position = np.position + (np.paused ? 0 : (Date.now() - received) / 1000);
+	position = Math.min(position, np.item.duration);
+
+	document.getElementById("bar").style.width = (np.item.duration > 0 ? 100 * position / np.item.duration : 0) + "%";
+	document.getElementById("time").textContent = format(position) + " / " + format(np.item.duration);
+}
+
+function move(id, to) {
+	fetch("/api/queue/move", {
+		method: "POST",
+		headers: { "Authorization": "Bearer " + token(), "Content-Type": "application/json" },
+		body: JSON.stringify({ id: id, to: to })
+	}).then(function(r) {
+		if (!r.ok) {
+			r.json().then(function(e) { document.getElementById("status").textContent = e.error; });
+		}
+	});
+}
+
+setInterval(tick, 1000);
+connect();
+</script>
+</body>
+</html>
+`
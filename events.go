@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const EventBufferSize = 64
+
+// Event is anything published on the EventBus, subscribers switch on the concrete type
+type Event interface {
+	Name() string
+}
+
+type TrackStarted struct {
+	Item *QueueItem
+}
+
+type TrackEnded struct {
+	Item *QueueItem
+}
+
+// TrackFailed is published instead of TrackEnded when the song couldn't be played to the end
+type TrackFailed struct {
+	Item *QueueItem
+	Err  error
+}
+
+type QueueChanged struct{}
+
+type Paused struct {
+	Item     *QueueItem
+	Position time.Duration
+}
+
+type Resumed struct {
+	Item     *QueueItem
+	Position time.Duration
+}
+
+// Seeked is published when the current song is restarted from another position
+type Seeked struct {
+	Item     *QueueItem
+	Position time.Duration
+}
+
+type VoiceConnected struct {
+	ChannelID string
+}
+
+type VoiceDisconnected struct {
+	ChannelID string
+}
+
+func (TrackStarted) Name() string      { return "trackStarted" }
+func (TrackEnded) Name() string        { return "trackEnded" }
+func (TrackFailed) Name() string       { return "trackFailed" }
+func (QueueChanged) Name() string      { return "queueChanged" }
+func (Paused) Name() string            { return "paused" }
+func (Resumed) Name() string           { return "resumed" }
+func (Seeked) Name() string            { return "seeked" }
+func (VoiceConnected) Name() string    { return "voiceConnected" }
+func (VoiceDisconnected) Name() string { return "voiceDisconnected" }
+
+// EventBus delivers events to subscribers, every subscriber has its own goroutine and buffer so a slow one can't stall playback
+type EventBus struct {
+	sync.RWMutex
+	subscriptions map[*Subscription]bool
+}
+
+type Subscription struct {
+	events  chan Event
+	handler func(event Event)
+}
+
+func CreateEventBus() *EventBus {
+	return &EventBus{
+		subscriptions: make(map[*Subscription]bool),
+	}
+}
+
+// Subscribe runs the handler for every published event, in the order they were published
+func (bus *EventBus) Subscribe(handler func(event Event)) *Subscription {
+	sub := &Subscription{
+		events:  make(chan Event, EventBufferSize),
+		handler: handler,
+	}
+
+	go func() {
+		for event := range sub.events {
+			sub.handler(event)
+		}
+	}()
+
+	bus.Lock()
+	bus.subscriptions[sub] = true
+	bus.Unlock()
+
+	return sub
+}
+
+func (bus *EventBus) Unsubscribe(sub *Subscription) {
+	bus.Lock()
+	defer bus.Unlock()
+
+	if bus.subscriptions[sub] {
+		delete(bus.subscriptions, sub)
+		close(sub.events)
+	}
+}
+
+// Publish never blocks, events are dropped for subscribers whose buffer is full
+func (bus *EventBus) Publish(event Event) {
+	if bus == nil {
+		return
+	}
+
+	bus.RLock()
+	defer bus.RUnlock()
+
+	for sub := range bus.subscriptions {
+		select {
+		case sub.events <- event:
+		default:
+			logrus.WithField("event", event.Name()).Warn("Event bus subscriber is too slow, event dropped")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collect subscribes to the bus and returns a function waiting for n events
+func collect(t *testing.T, bus *EventBus) (*Subscription, func(n int) []string) {
+	received := make(chan string, EventBufferSize)
+	sub := bus.Subscribe(func(event Event) {
+		received <- event.Name()
+	})
+
+	return sub, func(n int) []string {
+		var names []string
+		for len(names) < n {
+			select {
+			case name := <-received:
+				names = append(names, name)
+			case <-time.After(time.Second):
+				t.Fatalf("Got %v, waited for %d events", names, n)
+			}
+		}
+
+		select {
+		case name := <-received:
+			t.Errorf("Unexpected event %s", name)
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		return names
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	tests := []struct {
+		events []Event
+		want   []string
+	}{
+		{nil, nil},
+		{[]Event{QueueChanged{}}, []string{"queueChanged"}},
+		{[]Event{TrackStarted{}, Paused{}, Resumed{}, TrackEnded{}}, []string{"trackStarted", "paused", "resumed", "trackEnded"}},
+		{[]Event{VoiceConnected{}, TrackFailed{}, VoiceDisconnected{}}, []string{"voiceConnected", "trackFailed", "voiceDisconnected"}},
+	}
+
+	for _, test := range tests {
+		bus := CreateEventBus()
+		_, first := collect(t, bus)
+		_, second := collect(t, bus)
+
+		for _, event := range test.events {
+			bus.Publish(event)
+		}
+
+		for _, wait := range []func(int) []string{first, second} {
+			if got := wait(len(test.want)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Published %v, got %v, want %v", test.events, got, test.want)
+			}
+		}
+	}
+}
+
+func TestEventBusUnsubscribe(t *testing.T) {
+	bus := CreateEventBus()
+	sub, wait := collect(t, bus)
+
+	bus.Publish(QueueChanged{})
+	wait(1)
+
+	bus.Unsubscribe(sub)
+	bus.Unsubscribe(sub) // twice doesn't close the channel again
+	bus.Publish(QueueChanged{})
+	wait(0)
+}
+
+func TestEventBusSlowSubscriber(t *testing.T) {
+	bus := CreateEventBus()
+
+	block := make(chan bool)
+	defer close(block)
+	bus.Subscribe(func(event Event) {
+		<-block
+	})
+
+	// events over the buffer of the stuck subscriber are dropped instead of blocking
+	published := make(chan bool)
+	go func() {
+		for i := 0; i < 2*EventBufferSize; i++ {
+			bus.Publish(QueueChanged{})
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+}
+
+func TestEventBusNil(t *testing.T) {
+	var bus *EventBus
+	bus.Publish(QueueChanged{}) // queues made without a bus publish to nil
+}
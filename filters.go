@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FilterPresets are ffmpeg audio filter chains, see https://ffmpeg.org/ffmpeg-filters.html
+var FilterPresets = map[string]string{
+	"bassboost": "bass=g=10",
+	"nightcore": "asetrate=48000*1.25,aresample=48000",
+	"vaporwave": "asetrate=48000*0.8,aresample=48000",
+	"8d":        "apulsator=hz=0.125",
+	"karaoke":   "pan=stereo|c0=c0-c1|c1=c1-c0",
+	"normalize": "loudnorm",
+}
+
+var FilterAliases = map[string]string{
+	"vocalremoval": "karaoke",
+	"nc":           "nightcore",
+	"bass":         "bassboost",
+}
+
+type Filters struct {
+	sync.RWMutex
+	active []string
+}
+
+func (f *Filters) Add(name string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if alias, ok := FilterAliases[name]; ok {
+		name = alias
+	}
+
+	if _, ok := FilterPresets[name]; !ok {
+		return errors.New("No such filter")
+	}
+
+	for _, active := range f.active {
+		if active == name {
+			return errors.New("Filter is already active")
+		}
+	}
+
+	f.active = append(f.active, name)
+
+	return nil
+}
+
+func (f *Filters) Clear() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.active = nil
+}
+
+func (f *Filters) List() []string {
+	f.RLock()
+	defer f.RUnlock()
+
+	list := make([]string, len(f.active))
+	copy(list, f.active)
+
+	return list
+}
+
+// Chain joins active filters into a single ffmpeg filter chain, appended to the base ones (e.g. from encode options)
+func (f *Filters) Chain(base ...string) string {
+	f.RLock()
+	defer f.RUnlock()
+
+	var chain []string
+	for _, filter := range base {
+		if filter != "" {
+			chain = append(chain, filter)
+		}
+	}
+
+	for _, name := range f.active {
+		chain = append(chain, FilterPresets[name])
+	}
+
+	return strings.Join(chain, ",")
+}
+
+func (cmds *Commands) InitFilters() {
+	filter := CommandConstructor{
+		Names:             []string{"filter", "fx"},
+		Permission:        "filter",
+		DefaultPermission: true,
+		NoArguments:       false,
+		MinArguments:      0,
+		MaxArguments:      1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			if len(raw) == 0 || raw[0] == "" {
+				var presets []string
+				for name := range FilterPresets {
+					presets = append(presets, name)
+				}
+				sort.Strings(presets)
+
+				s.ChannelMessageSend(m.ChannelID, "Available filters: "+strings.Join(presets, ", ")+", off")
+				return nil
+			}
+
+			if raw[0] == "off" {
+				bot.Player.Filters.Clear()
+			} else {
+				err := bot.Player.Filters.Add(strings.ToLower(raw[0]))
+				if err != nil {
+					return err
+				}
+			}
+
+			// re-encode the current song from where it is now
+			return bot.Player.SendCommand(Restart)
+		},
+	}
+
+	cmds.RegisterCommands(&filter)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	HeartbeatInterval = 5 * time.Second
+	HeartbeatTimeout  = 3 * HeartbeatInterval // a player loop silent for longer is considered stuck
+)
+
+var HealthBinaries = []string{"ffmpeg", "youtube-dl"}
+
+// Heartbeat is beaten by a loop to show it isn't stuck
+type Heartbeat struct {
+	sync.Mutex
+	last time.Time
+}
+
+func (hb *Heartbeat) Beat() {
+	hb.Lock()
+	defer hb.Unlock()
+
+	hb.last = time.Now()
+}
+
+func (hb *Heartbeat) Since() time.Duration {
+	hb.Lock()
+	defer hb.Unlock()
+
+	return time.Since(hb.last)
+}
+
+type HealthCheck struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type HealthReport struct {
+	OK     bool                   `json:"ok"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+func (report *HealthReport) add(name string, ok bool, detail string) {
+	report.Checks[name] = HealthCheck{ok, detail}
+	report.OK = report.OK && ok
+}
+
+// InitHealth registers /healthz, failing only when the bot is stuck, and /readyz, failing whenever it can't play music
+func (bot *Bot) InitHealth(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, bot.Health(false))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, bot.Health(true))
+	})
+}
+
+// Health checks the player loop, readiness adds the gateway, the voice connection and the external binaries
+func (bot *Bot) Health(readiness bool) *HealthReport {
+	report := &HealthReport{
+		OK:     true,
+		Checks: make(map[string]HealthCheck),
+	}
+
+	player := bot.Player
+	if player == nil {
+		report.add("playerLoop", true, "not connected")
+	} else if since := player.Heartbeat.Since(); since > HeartbeatTimeout {
+		report.add("playerLoop", false, "no heartbeat for "+FormatDuration(since))
+	} else {
+		report.add("playerLoop", true, "")
+	}
+
+	if !readiness {
+		return report
+	}
+
+	if bot.DiscordSession != nil && bot.DiscordSession.DataReady {
+		report.add("gateway", true, "")
+	} else {
+		report.add("gateway", false, "not connected")
+	}
+
+	if player == nil {
+		report.add("voice", true, "not connected")
+	} else if player.VoiceConnection == nil || !player.VoiceConnection.Ready {
+		report.add("voice", false, "connection to "+player.GuildID+" is not ready")
+	} else {
+		report.add("voice", true, "connected to "+player.VoiceConnection.ChannelID)
+	}
+
+	for _, binary := range HealthBinaries {
+		path, err := exec.LookPath(binary)
+		if err != nil {
+			report.add(binary, false, err.Error())
+		} else {
+			report.add(binary, true, path)
+		}
+	}
+
+	return report
+}
+
+func writeHealthReport(w http.ResponseWriter, report *HealthReport) {
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+
+	writeAPIResponse(w, status, report)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHistorySize = 50
+	HistoryPageSize    = 10
+)
+
+type HistoryEntry struct {
+	Item     *QueueItem
+	PlayedAt time.Time
+}
+
+// History keeps recently played songs, newest first
+type History struct {
+	sync.RWMutex
+	size    int
+	entries []HistoryEntry
+}
+
+func CreateHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	return &History{
+		size: size,
+	}
+}
+
+func (h *History) Add(item *QueueItem) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.entries = append([]HistoryEntry{{item, time.Now()}}, h.entries...)
+
+	if len(h.entries) > h.size {
+		h.entries[len(h.entries)-1] = HistoryEntry{}
+		h.entries = h.entries[:h.size]
+	}
+}
+
+// Get returns i-th most recently played song, starting from 0
+func (h *History) Get(i int) (HistoryEntry, error) {
+	h.RLock()
+	defer h.RUnlock()
+
+	if len(h.entries) == 0 {
+		return HistoryEntry{}, errors.New("History is empty")
+	} else if i < 0 || len(h.entries) <= i {
+		return HistoryEntry{}, ErrItemNotFound{i}
+	}
+
+	return h.entries[i], nil
+}
+
+// Contains checks whether a song with the given ID was played recently
+func (h *History) Contains(id string) bool {
+	h.RLock()
+	defer h.RUnlock()
+
+	for _, entry := range h.entries {
+		if entry.Item.Info.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PlayedSince checks whether the exact queue item was played after the given time
+func (h *History) PlayedSince(item *QueueItem, since time.Time) bool {
+	h.RLock()
+	defer h.RUnlock()
+
+	for _, entry := range h.entries {
+		if entry.PlayedAt.Before(since) {
+			break
+		}
+
+		if entry.Item == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Random returns a random entry, except the ones with the given ID
+func (h *History) Random(exclude string) (HistoryEntry, error) {
+	h.RLock()
+	defer h.RUnlock()
+
+	var candidates []HistoryEntry
+	for _, entry := range h.entries {
+		if entry.Item.Info.ID != exclude {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return HistoryEntry{}, ErrNothingToAutoplay
+	}
+
+	return candidates[rand.Intn(len(candidates))], nil
+}
+
+// Page returns a copy of entries on the given page (0-indexed) and the total page count
+func (h *History) Page(page int) ([]HistoryEntry, int, error) {
+	h.RLock()
+	defer h.RUnlock()
+
+	if len(h.entries) == 0 {
+		return nil, 0, errors.New("History is empty")
+	}
+
+	pages := (len(h.entries) + HistoryPageSize - 1) / HistoryPageSize
+	if page < 0 || page >= pages {
+		return nil, pages, errors.New("Page doesn't exist")
+	}
+
+	end := (page + 1) * HistoryPageSize
+	if end > len(h.entries) {
+		end = len(h.entries)
+	}
+
+	entries := make([]HistoryEntry, end-page*HistoryPageSize)
+	copy(entries, h.entries[page*HistoryPageSize:end])
+
+	return entries, pages, nil
+}
+
+// replay puts i-th most recently played song back into the queue, right after the current one unless atEnd is set
+func replay(bot *Bot, i int, atEnd bool, requested *discordgo.User) error {
+	if bot.Player == nil {
+		return ErrPlayerNotConnected
+	}
+
+	entry, err := bot.History.Get(i)
+	if err != nil {
+		return err
+	}
+
+	err = bot.Blocklist.CheckItem(entry.Item)
+	if err != nil {
+		return err
+	}
+
+	item := *entry.Item
+	item.RequestedBy = requested.Username
+	item.RequesterID = requested.ID
+	item.AutoQueued = false
+
+	if atEnd {
+		return bot.Player.Add(&item)
+	}
+
+	return bot.Player.AddNext(&item)
+}
+
+func (cmds *Commands) InitHistory() {
+	history := CommandConstructor{
+		Names:             []string{"history", "hist", "h"},
+		Permission:        "history",
+		DefaultPermission: true,
+		NoArguments:       false,
+		MinArguments:      0,
+		MaxArguments:      1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			page := 1
+			if len(raw) > 0 && raw[0] != "" {
+				parsed, err := strconv.Atoi(raw[0])
+				if err != nil {
+					return err
+				}
+
+				page = parsed
+			}
+
+			entries, pages, err := bot.History.Page(page - 1) // pages are 0-index, but appears as 1-indexed to the user
+			if err != nil {
+				return err
+			}
+
+			var formatedList string
+			for pos, entry := range entries {
+				formatedList = strings.Join([]string{
+					formatedList,
+					strconv.Itoa((page-1)*HistoryPageSize + pos + 1), ". ",
+					entry.Item.Info.Title,
+					" (", entry.Item.RequestedBy, ", ", FormatDuration(time.Since(entry.PlayedAt)), " ago)\n",
+				}, "")
+			}
+			formatedList += fmt.Sprintf("Page %d/%d", page, pages)
+
+			s.ChannelMessageSend(m.ChannelID, formatedList)
+
+			return nil
+		},
+	}
+
+	previous := CommandConstructor{
+		Names:             []string{"previous", "prev"},
+		Permission:        "replay",
+		DefaultPermission: true,
+		NoArguments:       false,
+		MinArguments:      0,
+		MaxArguments:      1,
+		Undoable:          true,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			return replay(bot, 0, len(raw) > 0 && raw[0] == "end", m.Author)
+		},
+	}
+
+	replayCmd := CommandConstructor{
+		Names:             []string{"replay", "rp"},
+		Permission:        "replay",
+		DefaultPermission: true,
+		NoArguments:       false,
+		MinArguments:      1,
+		MaxArguments:      2,
+		Undoable:          true,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			i, err := strconv.Atoi(raw[0])
+			if err != nil {
+				return err
+			}
+			i-- // slices are 0-index, but appears as 1-indexed to the user
+
+			return replay(bot, i, len(raw) == 2 && raw[1] == "end", m.Author)
+		},
+	}
+
+	cmds.RegisterCommands(&history, &previous, &replayCmd)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DuplicatesAllow  = "allow"
+	DuplicatesWarn   = "warn"
+	DuplicatesReject = "reject"
+)
+
+type QueueLimits struct {
+	MaxPerUser  int           `yaml:"maxPerUser"`  // pending songs requested by a single user
+	MaxLength   int           `yaml:"maxLength"`   // songs in the whole queue
+	MaxDuration time.Duration `yaml:"maxDuration"` // length of a single song, e.g. 15m
+	Duplicates  string        `yaml:"duplicates"`  // what to do with songs already in the queue: allow (default), warn or reject
+}
+
+type ErrLimitExceeded struct {
+	title string
+	limit string
+}
+
+func (err ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("%s not queued: %s", err.title, err.limit)
+}
+
+type ErrLimitsExceeded []ErrLimitExceeded
+
+func (errs ErrLimitsExceeded) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// CheckLimits returns items that fit into the queue limits, the rest is reported in the error
+func (player *Player) CheckLimits(items ...*QueueItem) ([]*QueueItem, error) {
+	player.settingsLock.RLock()
+	limits := player.Limits
+	textChannel := player.TextChannel
+	player.settingsLock.RUnlock()
+
+	queue, _ := player.Queue.GetAll()
+	length := len(queue)
+	perUser := make(map[string]int)
+	queued := make(map[string]bool)
+	for i, item := range queue {
+		queued[item.Info.ID] = true
+
+		if i > 0 { // currently playing song isn't pending anymore
+			perUser[item.RequesterID]++
+		}
+	}
+
+	var accepted []*QueueItem
+	var rejected ErrLimitsExceeded
+
+	for _, item := range items {
+		if queued[item.Info.ID] {
+			switch limits.Duplicates {
+			case DuplicatesReject:
+				rejected = append(rejected, ErrLimitExceeded{item.Info.Title, "song is already in the queue"})
+				continue
+			case DuplicatesWarn:
+				player.DgoSession.ChannelMessageSend(textChannel, item.Info.Title+" is already in the queue")
+			}
+		}
+
+		if item.RequesterID != "" && player.BypassLimits(item.RequesterID) {
+			accepted = append(accepted, item)
+			queued[item.Info.ID] = true
+			continue
+		}
+
+		var limit string
+		if limits.MaxDuration > 0 && item.Info.Duration > limits.MaxDuration {
+			limit = "song is longer than " + FormatDuration(limits.MaxDuration)
+		} else if limits.MaxLength > 0 && length >= limits.MaxLength {
+			limit = fmt.Sprintf("queue is full (%d songs)", limits.MaxLength)
+		} else if limits.MaxPerUser > 0 && perUser[item.RequesterID] >= limits.MaxPerUser {
+			limit = fmt.Sprintf("%s already has %d songs queued", item.RequestedBy, limits.MaxPerUser)
+		}
+
+		if limit != "" {
+			rejected = append(rejected, ErrLimitExceeded{item.Info.Title, limit})
+			continue
+		}
+
+		accepted = append(accepted, item)
+		queued[item.Info.ID] = true
+		length++
+		perUser[item.RequesterID]++
+	}
+
+	if len(rejected) > 0 {
+		return accepted, rejected
+	}
+
+	return accepted, nil
+}
+
+func (cmds *Commands) InitLimits() {
+	// not a command, only a permission to ignore queue limits
+	bypassLimits := CommandConstructor{
+		Permission:        "bypassLimits",
+		DefaultPermission: false,
+	}
+
+	cmds.RegisterCommands(&bypassLimits)
+}
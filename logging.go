@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// InitLogging sets the level, info unless given, and the format, which is text unless json is asked for,
+// both are set every time so removing them from the config on reload brings the defaults back
+func InitLogging(level string, format string) error {
+	parsed := logrus.InfoLevel
+	if level != "" {
+		var err error
+		parsed, err = logrus.ParseLevel(level)
+		if err != nil {
+			return err
+		}
+	}
+
+	logrus.SetLevel(parsed)
+
+	if format == "json" {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	}
+
+	return nil
+}
+
+// commandLog carries the context of a chat command, the command is the first word of the message
+func (bot *Bot) commandLog(m *discordgo.MessageCreate) *logrus.Entry {
+	fields := logrus.Fields{
+		"guild":   bot.Config().Guild,
+		"channel": m.ChannelID,
+		"user":    m.Author.ID,
+	}
+
+	if parsed := strings.Fields(strings.TrimPrefix(m.Content, "!")); len(parsed) > 0 {
+		fields["command"] = parsed[0]
+	}
+
+	return logrus.WithFields(fields)
+}
+
+// log carries the guild and the voice channel the player is connected to
+func (player *Player) log() *logrus.Entry {
+	fields := logrus.Fields{
+		"guild": player.GuildID,
+	}
+
+	if player.VoiceConnection != nil {
+		fields["channel"] = player.VoiceConnection.ChannelID
+	}
+
+	return logrus.WithFields(fields)
+}
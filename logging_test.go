@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"testing"
+)
+
+func TestInitLogging(t *testing.T) {
+	defer InitLogging("", "")
+
+	tests := []struct {
+		level     string
+		format    string
+		want      logrus.Level
+		formatter logrus.Formatter
+		wantErr   bool
+	}{
+		{"debug", "json", logrus.DebugLevel, &logrus.JSONFormatter{}, false},
+		{"", "", logrus.InfoLevel, &logrus.TextFormatter{}, false}, // a reload removing both settings
+		{"warn", "text", logrus.WarnLevel, &logrus.TextFormatter{}, false},
+		{"loud", "json", logrus.WarnLevel, &logrus.TextFormatter{}, true}, // nothing changes
+	}
+
+	for _, test := range tests {
+		err := InitLogging(test.level, test.format)
+		if (err != nil) != test.wantErr {
+			t.Errorf("InitLogging(%q, %q) error = %v, want error %v", test.level, test.format, err, test.wantErr)
+		}
+		if got := logrus.GetLevel(); got != test.want {
+			t.Errorf("InitLogging(%q, %q) level = %v, want %v", test.level, test.format, got, test.want)
+		}
+		if got := logrus.StandardLogger().Formatter; reflect.TypeOf(got) != reflect.TypeOf(test.formatter) {
+			t.Errorf("InitLogging(%q, %q) formatter = %T, want %T", test.level, test.format, got, test.formatter)
+		}
+	}
+}
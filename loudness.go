@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+const (
+	DefaultTargetLoudness = -23.0 // LUFS, as recommended by EBU R128
+	MaxLoudnessGain       = 10.0  // dB, don't blow up quiet tracks into clipping
+)
+
+var integratedLoudnessRegexp = regexp.MustCompile(`I:\s+(-?[\d.]+) LUFS`)
+
+// LoudnessCache stores integrated loudness measured for each track, so it can be normalized on later plays
+type LoudnessCache struct {
+	sync.RWMutex
+	filepath string
+	target   float64
+	loudness map[string]float64
+}
+
+func LoadLoudnessCache(filePath string, target float64) *LoudnessCache {
+	if target == 0 {
+		target = DefaultTargetLoudness
+	}
+
+	lc := LoudnessCache{
+		filepath: filePath,
+		target:   target,
+		loudness: make(map[string]float64),
+	}
+
+	file, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).Error("Loading loudness cache failed")
+	}
+	if file != nil {
+		json.Unmarshal(file, &lc.loudness)
+	}
+
+	return &lc
+}
+
+func (lc *LoudnessCache) Save() {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	text, err := json.Marshal(lc.loudness)
+	if err != nil {
+		logrus.WithError(err).Error("Saving loudness cache failed")
+		return
+	}
+
+	err = ioutil.WriteFile(lc.filepath, text, 0644)
+	if err != nil {
+		logrus.WithError(err).Error("Saving loudness cache failed")
+	}
+}
+
+func (lc *LoudnessCache) Set(id string, loudness float64) {
+	lc.Lock()
+	lc.loudness[id] = loudness
+	lc.Unlock()
+
+	lc.Save()
+}
+
+// Gain returns volume adjustment in dB needed to reach the target loudness, if the track was measured before
+func (lc *LoudnessCache) Gain(id string) (float64, bool) {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	loudness, ok := lc.loudness[id]
+	if !ok {
+		return 0, false
+	}
+
+	gain := lc.target - loudness
+	if gain > MaxLoudnessGain {
+		gain = MaxLoudnessGain
+	}
+
+	return gain, true
+}
+
+// Filter returns ffmpeg volume filter for the track, or an empty string if it hasn't been measured yet
+func (lc *LoudnessCache) Filter(id string) string {
+	gain, ok := lc.Gain(id)
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("volume=%.2fdB", gain)
+}
+
+// Measure passes the source through while feeding it to ffmpeg's ebur128 filter, the result is stored once the whole source is read
+func (lc *LoudnessCache) Measure(id string, source io.Reader) (*LoudnessMeter, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-i", "pipe:0", "-af", "ebur128=framelog=quiet", "-f", "null", "-")
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	meter := &LoudnessMeter{
+		source: source,
+		cache:  lc,
+		id:     id,
+		cmd:    cmd,
+		stdin:  stdin,
+	}
+	cmd.Stderr = &meter.output
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	return meter, nil
+}
+
+type LoudnessMeter struct {
+	sync.Mutex
+	source   io.Reader
+	cache    *LoudnessCache
+	id       string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	output   bytes.Buffer
+	broken   bool
+	finished bool
+}
+
+func (lm *LoudnessMeter) Read(p []byte) (int, error) {
+	n, err := lm.source.Read(p)
+
+	lm.Lock()
+	if n > 0 && !lm.broken && !lm.finished {
+		// a failing meter must never break playback
+		if _, werr := lm.stdin.Write(p[:n]); werr != nil {
+			lm.broken = true
+		}
+	}
+	lm.Unlock()
+
+	if err == io.EOF {
+		go lm.finish()
+	}
+
+	return n, err
+}
+
+func (lm *LoudnessMeter) finish() {
+	lm.Lock()
+	defer lm.Unlock()
+
+	if lm.finished {
+		return
+	}
+	lm.finished = true
+
+	lm.stdin.Close()
+
+	err := lm.cmd.Wait()
+	if err != nil || lm.broken {
+		logrus.WithError(err).WithField("id", lm.id).Warn("Loudness measurement failed")
+		return
+	}
+
+	loudness, err := parseIntegratedLoudness(lm.output.String())
+	if err != nil {
+		logrus.WithError(err).WithField("id", lm.id).Warn("Loudness measurement failed")
+		return
+	}
+
+	lm.cache.Set(lm.id, loudness)
+}
+
+// Abort discards the measurement, used when the track doesn't get played until the end
+func (lm *LoudnessMeter) Abort() {
+	lm.Lock()
+	defer lm.Unlock()
+
+	if lm.finished {
+		return
+	}
+	lm.finished = true
+
+	lm.stdin.Close()
+	if lm.cmd.Process != nil {
+		lm.cmd.Process.Kill()
+	}
+	lm.cmd.Wait()
+}
+
+func parseIntegratedLoudness(output string) (float64, error) {
+	matches := integratedLoudnessRegexp.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, errors.New("No integrated loudness in ffmpeg output")
+	}
+
+	// the summary is printed last
+	return strconv.ParseFloat(matches[len(matches)-1][1], 64)
+}
@@ -2,62 +2,239 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/bwmarrin/discordgo"
-	"log"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2/jwt"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type Bot struct {
-	Config         *Configuration
+	config         *Configuration // replaced on reload, use Config()
+	configLock     sync.RWMutex
+	ConfigPath     string
 	Permissions    *PermissionsManager
 	Commands       *Commands
 	Player         *Player
+	Loudness       *LoudnessCache
+	History        *History
+	Playlists      *PlaylistStore
+	Blocklist      *Blocklist
+	YoutubeConfig  *jwt.Config
+	Events         *EventBus
+	Dashboard      *Dashboard
+	HTTPServer     *http.Server
 	DiscordSession *discordgo.Session
 }
 
+const (
+	HTTPReadTimeout  = 10 * time.Second
+	HTTPWriteTimeout = 30 * time.Second
+	HTTPIdleTimeout  = 2 * time.Minute
+)
+
 var (
-	Tanuki Bot
+	Tanuki     Bot
+	configPath = flag.String("c", "config.yml", "Config file path")
+	checkOnly  = flag.Bool("check", false, "Validate the config and permissions.json, then exit")
 )
 
-func init() {
-	Tanuki.Config = &Configuration{}
+// LoadConfig loads the config from the path given by the flags, it isn't done in init so the package can be tested
+func (bot *Bot) LoadConfig() {
+	config := &Configuration{}
+	bot.ConfigPath = *configPath
 
-	configPath := flag.String("c", "config.yml", "Config file path")
-	flag.Parse()
+	unknown, err := config.Load(bot.ConfigPath)
+	if err != nil {
+		logrus.WithError(err).WithField("path", bot.ConfigPath).Fatal("Loading config failed")
+	}
+	bot.setConfig(config)
+
+	err = InitLogging(config.LogLevel, config.LogFormat)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid logging settings, using defaults")
+	}
 
-	Tanuki.Config.Load(*configPath)
+	for _, key := range unknown {
+		logrus.WithField("path", bot.ConfigPath).Warn("Unknown or duplicate config key, " + key)
+	}
 }
 
-func (bot *Bot) Init() {
-	bot.Commands = CreateCommands()
+// Config returns the current config, it must not be modified since a reload replaces it as a whole
+func (bot *Bot) Config() *Configuration {
+	bot.configLock.RLock()
+	defer bot.configLock.RUnlock()
 
-	bot.Permissions = bot.Commands.InitPermissions("permissions.json")
-	bot.Commands.InitPlayer()
+	return bot.config
+}
+
+func (bot *Bot) setConfig(config *Configuration) {
+	bot.configLock.Lock()
+	defer bot.configLock.Unlock()
+
+	bot.config = config
+}
 
+func (bot *Bot) Init() {
 	var err error
-	bot.DiscordSession, err = discordgo.New(bot.Config.Token)
+
+	if bot.Config().YoutubeAPIKey != "" {
+		bot.YoutubeConfig, err = LoadYoutubeAPIConfig(bot.Config().YoutubeAPIKey)
+		if err != nil {
+			logrus.WithError(err).Error("Loading YouTube API key failed")
+		}
+	}
+
+	bot.Events = CreateEventBus()
+	bot.InitCommands()
+
+	bot.History = CreateHistory(bot.Config().HistorySize)
+
+	if bot.Config().Normalize {
+		bot.Loudness = LoadLoudnessCache("loudness.json", bot.Config().TargetLoudness)
+	}
+
+	bot.DiscordSession, err = discordgo.New(bot.Config().Token)
 	if err != nil {
-		log.Fatal(err)
+		logrus.WithError(err).Fatal("Creating discord session failed")
 		return
 	}
 
 	bot.DiscordSession.AddHandler(bot.ProcessCommand)
+	bot.DiscordSession.AddHandler(bot.ProcessReaction)
 	err = bot.DiscordSession.Open()
 	if err != nil {
-		log.Fatal(err)
+		logrus.WithError(err).Fatal("Connecting to discord failed")
 		return
 	}
+
+	if bot.Config().HTTPAddress != "" {
+		mux := http.NewServeMux()
+		bot.InitAPI(mux)
+		bot.Dashboard = bot.InitDashboard(mux)
+		bot.InitMetrics(mux)
+		bot.InitHealth(mux)
+
+		// the websocket upgrade clears these deadlines, the dashboard sets its own
+		bot.HTTPServer = &http.Server{
+			Addr:         bot.Config().HTTPAddress,
+			Handler:      mux,
+			ReadTimeout:  HTTPReadTimeout,
+			WriteTimeout: HTTPWriteTimeout,
+			IdleTimeout:  HTTPIdleTimeout,
+		}
+
+		go func() {
+			err := bot.HTTPServer.ListenAndServe()
+			if err != http.ErrServerClosed {
+				logrus.WithError(err).WithField("address", bot.Config().HTTPAddress).Error("HTTP server stopped")
+			}
+		}()
+	}
+}
+
+// InitCommands registers all commands along with the stores they use
+func (bot *Bot) InitCommands() {
+	bot.Commands = CreateCommands()
+
+	bot.Permissions = bot.Commands.InitPermissions(PermissionsFile)
+	bot.Commands.InitPlayer()
+	bot.Commands.InitFilters()
+	bot.Commands.InitHistory()
+	bot.Commands.InitAutoplay()
+	bot.Commands.InitLimits()
+	bot.Commands.InitUndo()
+	bot.Commands.InitReload()
+	bot.Blocklist = bot.Commands.InitBlocklist("blocklist.json", bot.YoutubeConfig)
+	bot.Playlists = bot.Commands.InitPlaylists("playlists.json")
+}
+
+// Check validates the config and the permissions file without connecting to discord
+func (bot *Bot) Check() error {
+	err := bot.Config().Validate()
+	if err != nil {
+		return err
+	}
+
+	permissions, err := LoadPermissions(PermissionsFile)
+	if err != nil {
+		return fmt.Errorf("%s: %s", PermissionsFile, err)
+	}
+
+	bot.InitCommands()
+
+	for _, channel := range bot.Config().Channels {
+		for _, name := range channel.Commands {
+			if bot.Commands.ByName[name] == nil && bot.Commands.ByPermission[name] == nil {
+				logrus.WithField("channel", channel.ID).Warn("Unknown command in channel allowlist: " + name)
+			}
+		}
+	}
+
+	for userID, user := range permissions {
+		for key := range user {
+			if bot.Commands.ByPermission[key] == nil {
+				logrus.WithField("user", userID).Warn("Unknown permission in " + PermissionsFile + ": " + key)
+			}
+		}
+	}
+
+	return nil
 }
 
 func main() {
+	flag.Parse()
+	Tanuki.LoadConfig()
+
+	if *checkOnly {
+		err := Tanuki.Check()
+		if err != nil {
+			logrus.WithError(err).Error("Check failed")
+			os.Exit(1)
+		}
+
+		logrus.Info("Config and permissions are valid")
+		return
+	}
+
+	err := Tanuki.Config().Validate()
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid config")
+	}
+
 	Tanuki.Init()
 
-	log.Println("Up and running!")
+	logrus.Info("Up and running!")
+
+	go Tanuki.WatchConfig()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			Tanuki.ReloadAndReport("SIGHUP")
+		}
+	}()
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 
-	Tanuki.DiscordSession.Close()
+	logrus.Info("Shutting down")
+
+	// a second signal doesn't wait for anything
+	go func() {
+		<-c
+		logrus.Warn("Forced to exit")
+		os.Exit(1)
+	}()
+
+	Tanuki.Shutdown(ShutdownTimeout)
+
+	logrus.Info("Bye!")
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonas747/dca"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	CommandsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tanuki_commands_total",
+		Help: "Commands run, by command and outcome (ok, error or denied).",
+	}, []string{"command", "outcome"})
+	PermissionDenials = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tanuki_permission_denials_total",
+		Help: "Requests refused because the user lacks the permission, from chat, reactions and the API.",
+	}, []string{"permission"})
+	QueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "tanuki_queue_length",
+		Help: "Songs in the queue, including the one playing.",
+	})
+	TracksPlayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tanuki_tracks_played_total",
+		Help: "Songs played to the end or skipped.",
+	}, []string{"source"})
+	TracksFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tanuki_tracks_failed_total",
+		Help: "Songs which stopped playing because of an error.",
+	}, []string{"source"})
+	EncodeStartLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "tanuki_encode_start_seconds",
+		Help:    "Time from starting the encoder until the first frame is sent, including the download start.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 16},
+	})
+	YoutubeDLFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tanuki_youtubedl_failures_total",
+		Help: "youtube-dl processes which couldn't be started or exited with an error.",
+	})
+	VoiceReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tanuki_voice_reconnects_total",
+		Help: "Gateway reconnects while connected to a voice channel, the voice connection is reestablished with them.",
+	})
+)
+
+// InitMetrics serves /metrics, the rest of metrics is collected from events
+func (bot *Bot) InitMetrics(mux *http.ServeMux) {
+	mux.Handle("/metrics", promhttp.Handler())
+
+	bot.Events.Subscribe(func(event Event) {
+		switch e := event.(type) {
+		case QueueChanged:
+			if player := bot.Player; player != nil {
+				QueueLength.Set(float64(player.Queue.Len()))
+			}
+		case VoiceDisconnected:
+			QueueLength.Set(0)
+		case TrackEnded:
+			TracksPlayed.WithLabelValues(trackSource(e.Item)).Inc()
+		case TrackFailed:
+			TracksFailed.WithLabelValues(trackSource(e.Item)).Inc()
+		}
+	})
+
+	// the session is open already, so every connect is a reconnect, discordgo reconnects voice along with the gateway
+	bot.DiscordSession.AddHandler(func(s *discordgo.Session, c *discordgo.Connect) {
+		if bot.Player != nil {
+			VoiceReconnects.Inc()
+		}
+	})
+}
+
+// trackSource is the part of the item ID before the colon, e.g. youtube
+func trackSource(item *QueueItem) string {
+	if i := strings.Index(item.Info.ID, ":"); i > 0 {
+		return item.Info.ID[:i]
+	}
+
+	return "unknown"
+}
+
+// countDenial records a refused permission check, for the command given, if any
+func countDenial(cmd *CommandConstructor) {
+	PermissionDenials.WithLabelValues(cmd.Permission).Inc()
+
+	if len(cmd.Names) > 0 {
+		CommandsTotal.WithLabelValues(cmd.Names[0], "denied").Inc()
+	}
+}
+
+// encodeTimer observes the encode start latency when the streamer reads the first frame
+type encodeTimer struct {
+	dca.OpusReader
+	started time.Time
+	once    sync.Once
+}
+
+func timeEncodeStart(reader dca.OpusReader, started time.Time) dca.OpusReader {
+	return &encodeTimer{
+		OpusReader: reader,
+		started:    started,
+	}
+}
+
+func (t *encodeTimer) OpusFrame() ([]byte, error) {
+	frame, err := t.OpusReader.OpusFrame()
+
+	if err == nil {
+		t.once.Do(func() {
+			EncodeStartLatency.Observe(time.Since(t.started).Seconds())
+		})
+	}
+
+	return frame, err
+}
@@ -8,19 +8,24 @@ import (
 	"github.com/jonas747/dca"
 	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/youtube/v3"
-	"log"
+	"io"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Player struct {
 	IsPlaying        bool
+	Loop             bool
+	Autoplay         bool // guarded by settingsLock like the other settings a reload can change
 	Queue            Queue
+	Filters          Filters
 	SongChannel      chan *QueueItem
 	CommandsChannel  chan PlayerCommand
+	SeekChannel      chan time.Duration
 	Position         chan time.Duration
 	QuitChannel      chan bool
 	VoiceConnection  *discordgo.VoiceConnection
@@ -28,20 +33,44 @@ type Player struct {
 	Streamer         *dca.StreamingSession
 	ClientConfig     *jwt.Config
 	GuildID          string
+	TextChannel      string
 	DgoSession       *discordgo.Session
+	NowPlaying       *NowPlaying
+	QueueView        *QueueView
+	Undo             *UndoLog
+	Loudness         *LoudnessCache
+	History          *History
+	Blocklist        *Blocklist
+	Limits           QueueLimits
+	BypassLimits     func(userID string) bool
+	Heartbeat        Heartbeat // beaten by the playing goroutine, while idle too
+	Events           *EventBus
+	meter            *LoudnessMeter // measurement of the current song, only touched by the playing goroutine
+	settingsLock     sync.RWMutex
 }
 
 type PlayerCommand int
 
 var (
-	ErrPlayerConnected    error = errors.New("Player is already connected, use !stop")
-	ErrPlayerNotConnected error = errors.New("Player is not connected, use !join")
+	ErrPlayerConnected     error = errors.New("Player is already connected, use !stop")
+	ErrPlayerNotConnected  error = errors.New("Player is not connected, use !join")
+	ErrVoiceNotReady       error = errors.New("Voice connection isn't ready")
+	ErrStreamFailed        error = errors.New("Song couldn't be downloaded")
+	ErrPlayerNotResponding error = errors.New("Player isn't responding, try again")
 )
 
 const (
-	Stop PlayerCommand = iota
+	VoiceReadyTimeout = 10 * time.Second // how long a song waits for the voice connection before failing
+	VoiceReadyPoll    = 50 * time.Millisecond
+	CommandTimeout    = 5 * time.Second // how long commands wait for the playing goroutine, the song may be ending
+)
+
+const (
+	Stop PlayerCommand = iota // skips the current song
 	Pause
 	Position
+	Restart
+	Quit // stops the current song without autoplay picking another one, used when purging
 )
 
 func (cmds *Commands) InitPlayer() {
@@ -54,24 +83,29 @@ func (cmds *Commands) InitPlayer() {
 		NoArguments:       false,
 		MinArguments:      1,
 		MaxArguments:      -1,
+		Undoable:          true,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
 			if bot.Player == nil {
 				return ErrPlayerNotConnected
 			}
 
-			youtubeRegexp := regexp.MustCompile(`youtu(?:be\.com/(?:v/|e(?:mbed)?/|watch\?v=)|\.be/)([\w-]{11}\b)`)
-
 			for _, link := range raw {
-				id := youtubeRegexp.FindStringSubmatch(link)
+				id := YoutubeVideoRegexp.FindStringSubmatch(link)
 
 				if len(id) > 0 {
-					yt, err := CreateQueueItem(id[1], m.Author.Username)
-					if err != nil {
-						log.Println(err)
+					yt, err := CreateQueueItem(id[1], m.Author, bot.Blocklist)
+					if _, blocked := err.(ErrBlocked); blocked {
+						s.ChannelMessageSend(m.ChannelID, err.Error())
+						continue
+					} else if err != nil {
+						bot.commandLog(m).WithError(err).Warn("Queueing video failed")
 						continue
 					}
 
-					bot.Player.Add(yt)
+					err = bot.Player.Add(yt)
+					if err != nil {
+						s.ChannelMessageSend(m.ChannelID, err.Error())
+					}
 				} else {
 					s.ChannelMessageSend(m.ChannelID, "No video matched")
 				}
@@ -87,6 +121,7 @@ func (cmds *Commands) InitPlayer() {
 		NoArguments:       false,
 		MinArguments:      1,
 		MaxArguments:      -1,
+		Undoable:          true,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
 			if bot.Player == nil {
 				return ErrPlayerNotConnected
@@ -103,25 +138,28 @@ func (cmds *Commands) InitPlayer() {
 				return err
 			}
 
+			var rejected error
 			for _, link := range raw {
 				id := listRegexp.FindStringSubmatch(link)
 
 				if len(id) > 0 {
 					items := make(chan *QueueItem)
 
-					err := RetrievePlaylist(service, id[1], m.Author.Username, items)
+					err := RetrievePlaylist(service, id[1], m.Author, bot.Blocklist, items)
 					if err != nil {
-						log.Println(err)
+						bot.commandLog(m).WithError(err).Warn("Retrieving playlist failed")
 						continue
 					}
 
 					for item := range items {
-						bot.Player.Add(item)
+						if err := bot.Player.Add(item); err != nil {
+							rejected = err
+						}
 					}
 				}
 			}
 
-			return nil
+			return rejected
 		},
 	}
 
@@ -137,8 +175,7 @@ func (cmds *Commands) InitPlayer() {
 				return ErrPlayerNotConnected
 			}
 
-			bot.Player.SendCommand(Stop)
-			return nil
+			return bot.Player.SendCommand(Stop)
 		},
 	}
 
@@ -150,50 +187,28 @@ func (cmds *Commands) InitPlayer() {
 		MinArguments:      0,
 		MaxArguments:      -1,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
-			if bot.Player == nil {
-				return ErrPlayerNotConnected
-			}
-
-			err := bot.Player.Stop()
-			if err != nil {
-				return err
-			}
-
-			bot.Player = nil
-
-			return nil
+			return bot.StopPlayer()
 		},
 	}
 
 	playlist := CommandConstructor{
 		Names:             []string{"playlist", "list", "pls"},
 		Permission:        "playlist",
-		NoArguments:       true,
+		NoArguments:       false,
 		DefaultPermission: true,
 		MinArguments:      0,
-		MaxArguments:      -1,
+		MaxArguments:      3,
+		Undoable:          true,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
-			if bot.Player == nil {
-				return ErrPlayerNotConnected
-			}
-
-			var formatedList string
-
-			queue, remaining, err := bot.Player.Queue.GetFirstN(10)
-			if err != nil {
-				return err
+			if len(raw) > 0 && raw[0] != "" {
+				return RunPlaylistSubcommand(bot, raw, m, s)
 			}
 
-			//TODO use embed(s)
-			for pos, item := range queue {
-				formatedList = strings.Join([]string{formatedList, strconv.Itoa(pos + 1), ". ", item.Info.Title, "\n"}, "")
-			}
-			if remaining > 0 {
-				formatedList += fmt.Sprintf("+ %d more...", remaining)
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
 			}
-			s.ChannelMessageSend(m.ChannelID, formatedList)
 
-			return nil
+			return bot.Player.QueueView.Show(bot.Player, m.ChannelID)
 		},
 	}
 
@@ -204,18 +219,14 @@ func (cmds *Commands) InitPlayer() {
 		NoArguments:       false,
 		MinArguments:      1,
 		MaxArguments:      2,
+		Undoable:          true,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
 			if bot.Player == nil {
 				return ErrPlayerNotConnected
 			}
 
-			var moveFrom, moveTo int
-
-			moveFrom, err := strconv.Atoi(raw[0])
-			if err != nil {
-				return err
-			}
-			moveFrom-- // slices are 0-index, but appears as 1-indexed to the user
+			var moveTo int
+			var err error
 
 			if len(raw) == 2 {
 				moveTo, err = strconv.Atoi(raw[1])
@@ -227,7 +238,7 @@ func (cmds *Commands) InitPlayer() {
 			}
 			moveTo-- // slices are 0-index, but appears as 1-indexed to the user
 
-			return bot.Player.Queue.Move(moveFrom, moveTo)
+			return bot.Player.Queue.MoveRef(raw[0], moveTo)
 		},
 	}
 
@@ -237,28 +248,54 @@ func (cmds *Commands) InitPlayer() {
 		DefaultPermission: true,
 		NoArguments:       false,
 		MinArguments:      1,
-		MaxArguments:      1,
+		MaxArguments:      -1,
+		Undoable:          true,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
 			if bot.Player == nil {
 				return ErrPlayerNotConnected
 			}
 
-			i, err := strconv.Atoi(raw[0])
-			if err != nil {
-				return err
-			}
+			var removed int
+			joined := strings.Join(raw, " ")
 
-			if i < 1 {
-				return nil
+			// titles are only searched when asked for explicitly, a mistyped or stale ID mustn't remove anything else
+			if len(m.Mentions) > 0 {
+				for _, user := range m.Mentions {
+					removed += bot.Player.Queue.RemoveRequestedBy(user.ID)
+				}
+			} else if title, ok := titleSearch(joined); ok {
+				removed = bot.Player.Queue.RemoveByTitle(title)
+			} else if len(raw) == 1 && IsItemID(raw[0]) {
+				return bot.Player.Queue.RemoveRef(raw[0])
+			} else if indices, err := parseIndices(strings.Join(raw, ",")); err == nil {
+				removed = bot.Player.Queue.RemoveIndices(indices...)
+			} else {
+				return errors.New("Usage: !remove <positions>, #<id>, title:<text>, \"<text>\" or @user")
 			}
 
-			if i == 1 {
-				return errors.New("Cannot remove currently playing song")
+			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Removed %d songs", removed))
+
+			return nil
+		},
+	}
+
+	removeMine := CommandConstructor{
+		Names:             []string{"removemine", "rmine"},
+		Permission:        "removeMine",
+		DefaultPermission: true,
+		NoArguments:       true,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		Undoable:          true,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
 			}
 
-			i-- // slices are 0-index, but appears as 1-indexed to the user
+			removed := bot.Player.Queue.RemoveRequestedBy(m.Author.ID)
+			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Removed %d songs", removed))
 
-			return bot.Player.Queue.Remove(i)
+			return nil
 		},
 	}
 
@@ -275,20 +312,22 @@ func (cmds *Commands) InitPlayer() {
 			}
 
 			// for some reason I can't get voice states right after opening a session, so let's retrieve a guild here
-			guild, err := bot.DiscordSession.Guild(bot.Config.Guild)
+			guild, err := bot.DiscordSession.Guild(bot.Config().Guild)
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
 
 			for _, vState := range guild.VoiceStates {
 				if vState.UserID == m.Author.ID {
 					vc, err := s.ChannelVoiceJoin(vState.GuildID, vState.ChannelID, false, false)
 					if err != nil {
-						log.Println(err)
-						return nil
+						return err
 					}
 
-					bot.Player = CreatePlayer(bot.Config, s, vc)
+					bot.Player = CreatePlayer(bot, vc)
+					bot.Events.Publish(VoiceConnected{vState.ChannelID})
+
+					go bot.RestoreQueue(QueueFile)
 				}
 			}
 
@@ -308,24 +347,22 @@ func (cmds *Commands) InitPlayer() {
 				return ErrPlayerNotConnected
 			}
 
-			var id int = 0
-			if len(raw) > 0 {
-				if raw[0] != "" {
-					parsed, err := strconv.Atoi(raw[0])
-					if err != nil {
-						return err
-					}
-
-					id = parsed - 1
-				}
+			ref := "1"
+			if len(raw) > 0 && raw[0] != "" {
+				ref = raw[0]
 			}
 
-			song, err := bot.Player.Queue.Get(id)
+			song, err := bot.Player.Queue.GetRef(ref)
 			if err != nil {
 				return err
 			}
 
+			current, _ := bot.Player.Queue.GetFirst()
+
 			embed := &discordgo.MessageEmbed{
+				Footer: &discordgo.MessageEmbedFooter{
+					Text: "#" + song.ID,
+				},
 				Fields: []*discordgo.MessageEmbedField{
 					{
 						Name:  "Title:",
@@ -342,12 +379,19 @@ func (cmds *Commands) InitPlayer() {
 					},
 					{
 						Name:   "Length:",
-						Value:  song.Info.Duration,
+						Value:  FormatDuration(song.Info.Duration),
 						Inline: true,
 					},
 				},
 			}
 
+			if filters := bot.Player.Filters.List(); song == current && len(filters) > 0 {
+				embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+					Name:  "Filters:",
+					Value: strings.Join(filters, ", "),
+				})
+			}
+
 			_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
 
 			return err
@@ -366,8 +410,7 @@ func (cmds *Commands) InitPlayer() {
 				return ErrPlayerNotConnected
 			}
 
-			bot.Player.SendCommand(Pause)
-			return nil
+			return bot.Player.SendCommand(Pause)
 		},
 	}
 
@@ -378,14 +421,13 @@ func (cmds *Commands) InitPlayer() {
 		NoArguments:       true,
 		MinArguments:      0,
 		MaxArguments:      -1,
+		Undoable:          true,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
 			if bot.Player == nil {
 				return ErrPlayerNotConnected
 			}
 
-			bot.Player.Purge()
-
-			return nil
+			return bot.Player.Purge()
 		},
 	}
 
@@ -396,6 +438,7 @@ func (cmds *Commands) InitPlayer() {
 		NoArguments:       false,
 		MinArguments:      1,
 		MaxArguments:      -1,
+		Undoable:          true,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
 			if bot.Player == nil {
 				return ErrPlayerNotConnected
@@ -410,14 +453,12 @@ func (cmds *Commands) InitPlayer() {
 				return err
 			}
 
-			item, err := Find(service, strings.Join(raw, " "), m.Author.Username)
+			item, err := Find(service, strings.Join(raw, " "), m.Author, bot.Blocklist)
 			if err != nil {
-				log.Println(err)
+				return err
 			}
 
-			bot.Player.Add(item)
-
-			return nil
+			return bot.Player.Add(item)
 		},
 	}
 
@@ -429,37 +470,268 @@ func (cmds *Commands) InitPlayer() {
 		MinArguments:      0,
 		MaxArguments:      -1,
 		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
-			bot.Player.SendCommand(Position)
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			s.ChannelMessageSend(m.ChannelID, "Current position: "+bot.Player.CurrentPosition().String())
+			return nil
+		},
+	}
+
+	shuffle := CommandConstructor{
+		Names:             []string{"shuffle", "shuf"},
+		Permission:        "shuffle",
+		DefaultPermission: true,
+		NoArguments:       true,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		Undoable:          true,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			return bot.Player.Queue.Shuffle()
+		},
+	}
+
+	loop := CommandConstructor{
+		Names:             []string{"loop", "l"},
+		Permission:        "loop",
+		DefaultPermission: true,
+		NoArguments:       true,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			bot.Player.Loop = !bot.Player.Loop
+
+			if bot.Player.Loop {
+				s.ChannelMessageSend(m.ChannelID, "Queue loop enabled")
+			} else {
+				s.ChannelMessageSend(m.ChannelID, "Queue loop disabled")
+			}
+
+			return nil
+		},
+	}
+
+	dedupe := CommandConstructor{
+		Names:             []string{"dedupe", "dd"},
+		Permission:        "dedupe",
+		DefaultPermission: true,
+		NoArguments:       true,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		Undoable:          true,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			removed := bot.Player.Queue.Dedupe()
+			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Removed %d duplicate songs", removed))
 
-			s.ChannelMessageSend(m.ChannelID, "Current position: "+(<-bot.Player.Position).String())
 			return nil
 		},
 	}
 
-	cmds.RegisterCommands(&queueSong, &queueList, &skip, &stop, &playlist, &move, &remove, &info, &join, &pause, &purge, &find, &position)
+	playNext := CommandConstructor{
+		Names:             []string{"playnext", "pn"},
+		Permission:        "playNext",
+		DefaultPermission: true,
+		NoArguments:       false,
+		MinArguments:      1,
+		MaxArguments:      -1,
+		Undoable:          true,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			var items []*QueueItem
+			for _, link := range raw {
+				id := YoutubeVideoRegexp.FindStringSubmatch(link)
+
+				if len(id) > 0 {
+					yt, err := CreateQueueItem(id[1], m.Author, bot.Blocklist)
+					if _, blocked := err.(ErrBlocked); blocked {
+						s.ChannelMessageSend(m.ChannelID, err.Error())
+						continue
+					} else if err != nil {
+						bot.commandLog(m).WithError(err).Warn("Queueing video failed")
+						continue
+					}
+
+					items = append(items, yt)
+				} else {
+					s.ChannelMessageSend(m.ChannelID, "No video matched")
+				}
+			}
+
+			return bot.Player.AddNext(items...)
+		},
+	}
+
+	jump := CommandConstructor{
+		Names:             []string{"jump", "jmp"},
+		Permission:        "jump",
+		DefaultPermission: true,
+		NoArguments:       false,
+		MinArguments:      1,
+		MaxArguments:      2,
+		Undoable:          true,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			err := bot.Player.Queue.JumpRef(raw[0], len(raw) == 2 && raw[1] == "keep")
+			if err != nil {
+				return err
+			}
+
+			return bot.Player.SendCommand(Stop)
+		},
+	}
+
+	seek := CommandConstructor{
+		Names:             []string{"seek"},
+		Permission:        "seek",
+		DefaultPermission: true,
+		NoArguments:       false,
+		MinArguments:      1,
+		MaxArguments:      1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			position, err := ParseDuration(raw[0])
+			if err != nil {
+				return err
+			}
+
+			return bot.Player.Seek(position)
+		},
+	}
+
+	cmds.RegisterCommands(&queueSong, &queueList, &skip, &stop, &playlist, &move, &remove, &info, &join, &pause, &purge, &find, &position, &shuffle, &loop, &dedupe, &removeMine, &playNext, &jump, &seek)
 }
 
-func CreatePlayer(config *Configuration, session *discordgo.Session, voice *discordgo.VoiceConnection) *Player {
+// titleSearch extracts the text of title:<text> or "<text>"
+func titleSearch(text string) (string, bool) {
+	if strings.HasPrefix(text, "title:") {
+		text = strings.TrimSpace(strings.TrimPrefix(text, "title:"))
+		return text, text != ""
+	}
+
+	if len(text) > 2 && strings.HasPrefix(text, "\"") && strings.HasSuffix(text, "\"") {
+		return text[1 : len(text)-1], true
+	}
+
+	return "", false
+}
+
+// parseIndices parses lists and ranges of 1-indexed positions like 2,5,9 or 3-7 into 0-indexed ones,
+// empty parts are skipped so arguments like "2, 5" work too
+func parseIndices(spec string) ([]int, error) {
+	var indices []int
+
+	for _, part := range strings.Split(spec, ",") {
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		to := from
+		if len(bounds) == 2 {
+			to, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if from > to || to-from > 1000 {
+			return nil, errors.New("Invalid range")
+		}
+
+		for i := from; i <= to; i++ {
+			indices = append(indices, i-1) // slices are 0-index, but appears as 1-indexed to the user
+		}
+	}
+
+	if len(indices) == 0 {
+		return nil, errors.New("No positions given")
+	}
+
+	return indices, nil
+}
+
+// StopPlayer stops the player and leaves the voice channel
+func (bot *Bot) StopPlayer() error {
+	if bot.Player == nil {
+		return ErrPlayerNotConnected
+	}
+
+	err := bot.Player.Stop()
+	if err != nil {
+		return err
+	}
+
+	channelID := bot.Player.VoiceConnection.ChannelID
+	bot.Player = nil
+	bot.Events.Publish(VoiceDisconnected{channelID})
+
+	return nil
+}
+
+func CreatePlayer(bot *Bot, voice *discordgo.VoiceConnection) *Player {
+	config := bot.Config()
+
 	player := Player{
 		Queue:            Queue{},
+		Events:           bot.Events,
 		EncodingSettings: &config.EncodeOptions,
 		SongChannel:      make(chan *QueueItem, 1),
 		CommandsChannel:  make(chan PlayerCommand),
+		SeekChannel:      make(chan time.Duration),
 		Position:         make(chan time.Duration, 1),
 		QuitChannel:      make(chan bool),
-		DgoSession:       session,
+		DgoSession:       bot.DiscordSession,
 		VoiceConnection:  voice,
+		GuildID:          voice.GuildID,
+		TextChannel:      config.TextChannel,
+		Autoplay:         config.Autoplay,
+		Limits:           config.Limits,
+		NowPlaying:       CreateNowPlaying(bot.DiscordSession, config.TextChannel),
+		QueueView:        CreateQueueView(bot.DiscordSession),
+		Undo:             CreateUndoLog(UndoLogSize),
+		Loudness:         bot.Loudness,
+		History:          bot.History,
+		Blocklist:        bot.Blocklist,
+		ClientConfig:     bot.YoutubeConfig,
+		BypassLimits: func(userID string) bool {
+			return bot.HasPermission(userID, bot.Commands.ByPermission["bypassLimits"])
+		},
 	}
-
-	if config.YoutubeAPIKey != "" {
-		var err error
-		player.ClientConfig, err = LoadYoutubeAPIConfig(config.YoutubeAPIKey)
-		if err != nil {
-			log.Println(err)
-		}
-	}
+	player.Queue.Events = bot.Events
 
 	go func() {
+		heartbeat := time.NewTicker(HeartbeatInterval)
+		defer heartbeat.Stop()
+		player.Heartbeat.Beat()
+
 		for {
 			select {
 			case song := <-player.SongChannel:
@@ -467,20 +739,54 @@ func CreatePlayer(config *Configuration, session *discordgo.Session, voice *disc
 
 				player.DgoSession.UpdateStatus(0, song.Info.Title)
 				//player.DgoSession.ChannelTopicEdit(config.TextChannel, "Playing: "+song.Info.Title)
+				// discord requests can be slow, the loop isn't stuck while they're made
+				player.Heartbeat.Beat()
+				player.NowPlaying.Start(song)
+				player.Heartbeat.Beat()
+				player.Events.Publish(TrackStarted{song})
 
-				player.Play(song.Stream)
+				quit, err := player.Play(song)
+
+				if err != nil {
+					player.log().WithError(err).WithField("song", song.Info.ID).Error("Playing song failed")
+					player.Events.Publish(TrackFailed{song, err})
+				} else {
+					player.Events.Publish(TrackEnded{song})
+				}
 
 				player.DgoSession.UpdateStatus(0, "")
 				//player.DgoSession.ChannelTopicEdit(config.TextChannel, "")
 
-				player.Queue.Remove(0)
+				player.History.Add(song)
+
+				if player.Loop {
+					player.Queue.Move(0, player.Queue.Len()-1)
+				} else {
+					player.Queue.Remove(0)
+				}
+
+				if player.AutoplayEnabled() && !quit && player.Queue.Len() == 0 {
+					player.Heartbeat.Beat()
+					next, err := player.NextAutoplay(song)
+					player.Heartbeat.Beat()
+					if err != nil {
+						player.log().WithError(err).Warn("Autoplay found nothing to play")
+					} else {
+						player.Queue.Add(next)
+					}
+				}
 
-				song, err := player.Queue.GetFirst()
+				song, err = player.Queue.GetFirst()
 				if err == nil {
 					player.SongChannel <- song
+				} else {
+					player.NowPlaying.Clear()
 				}
+			case <-heartbeat.C:
+				player.Heartbeat.Beat()
 			case <-player.QuitChannel:
 				player.IsPlaying = false
+				player.NowPlaying.Clear()
 				return
 			}
 			player.IsPlaying = false
@@ -490,63 +796,196 @@ func CreatePlayer(config *Configuration, session *discordgo.Session, voice *disc
 	return &player
 }
 
-func (player *Player) Play(stream Playable) {
-	encoder, err := dca.EncodeMem(stream.Play(), player.EncodingSettings)
-	defer encoder.Cleanup()
+// Play streams the song until it ends or is stopped, quit is set when it was stopped by Quit, the error says why it ended early
+func (player *Player) Play(song *QueueItem) (quit bool, err error) {
+	stream := song.Stream
+	defer stream.Stop() // also collects youtube-dl when the song ended by itself
+	started := time.Now()
+
+	// runs before the stream is stopped, so a partly read song isn't stored as measured
+	defer func() {
+		if err != nil {
+			player.abortMeasurement()
+		}
+		player.meter = nil
+	}()
+
+	encoder, err := player.Encode(stream, 0)
 	if err != nil {
-		log.Println(err)
-		return
+		return false, err
 	}
+	defer func() {
+		if encoder != nil {
+			encoder.Cleanup()
+		}
+	}()
 
 	player.VoiceConnection.Speaking(true)
 	defer player.VoiceConnection.Speaking(false)
 
-	for player.VoiceConnection.Ready == false {
-		runtime.Gosched()
+	waitStart := time.Now()
+	for !player.VoiceConnection.Ready {
+		if time.Since(waitStart) > VoiceReadyTimeout {
+			return false, ErrVoiceNotReady
+		}
+
+		player.Heartbeat.Beat()
+		time.Sleep(VoiceReadyPoll)
 	}
 
-	done := make(chan error)
-	player.Streamer = dca.NewStream(encoder, player.VoiceConnection, done)
+	// done is buffered so streamers replaced on restart don't block forever
+	done := make(chan error, 1)
+	player.Streamer = dca.NewStream(timeEncodeStart(encoder, started), player.VoiceConnection, done)
+
+	// PlaybackPosition starts from zero with every new streamer, offset keeps track of where it was restarted
+	var offset time.Duration
+	position := func() time.Duration {
+		return offset + player.Streamer.PlaybackPosition()
+	}
+
+	// restart encodes the stream again from the given position, used for seeking and applying filters
+	restart := func(at time.Duration) error {
+		offset = at
+		paused := player.Streamer.Paused()
+		started := time.Now()
+
+		player.abortMeasurement()
+		stream.Stop()
+		encoder.Stop()
+		encoder.Cleanup()
+		encoder = nil // already cleaned up if encoding again fails
+
+		next, err := player.Encode(stream, offset)
+		if err != nil {
+			return err
+		}
+		encoder = next
+
+		done = make(chan error, 1)
+		player.Streamer = dca.NewStream(timeEncodeStart(encoder, started), player.VoiceConnection, done)
+		player.Streamer.SetPaused(paused)
+
+		return nil
+	}
+
+	ticker := time.NewTicker(NowPlayingInterval)
+	defer ticker.Stop()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
 
 	//wait for commands
 	for {
 		select {
 		case cmd := <-player.CommandsChannel:
 			switch cmd {
-			case Stop:
+			case Stop, Quit:
+				player.abortMeasurement()
 				stream.Stop()
 				encoder.Stop()
-				return
+				return cmd == Quit, nil
 			case Pause:
 				if player.Streamer.Paused() {
 					player.Streamer.SetPaused(false)
+					player.Events.Publish(Resumed{song, position()})
 				} else {
 					player.Streamer.SetPaused(true)
+					player.Events.Publish(Paused{song, position()})
 				}
+				player.NowPlaying.Update(position(), player.Streamer.Paused())
 				break
 			case Position:
-				player.Position <- player.Streamer.PlaybackPosition()
+				player.Position <- position()
+				break
+			case Restart:
+				if err := restart(position()); err != nil {
+					return false, err
+				}
 				break
 			}
 
+		case target := <-player.SeekChannel:
+			if err := restart(target); err != nil {
+				return false, err
+			}
+			player.NowPlaying.Update(position(), player.Streamer.Paused())
+			player.Events.Publish(Seeked{song, target})
+
+		case <-ticker.C:
+			player.NowPlaying.Update(position(), player.Streamer.Paused())
+
+		case <-heartbeat.C:
+			player.Heartbeat.Beat()
+
 		case err := <-done:
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+}
+
+// Encode starts encoding the stream from the given position, with currently active filters applied
+func (player *Player) Encode(stream Playable, start time.Duration) (*dca.EncodeSession, error) {
+	player.settingsLock.RLock()
+	options := *player.EncodingSettings
+	player.settingsLock.RUnlock()
+	options.StartTime = int(start.Seconds())
+
+	source := stream.Play()
+	if source == nil {
+		return nil, ErrStreamFailed
+	}
+
+	var volume string
+
+	if player.Loudness != nil {
+		id := stream.GetInfo().ID
+
+		volume = player.Loudness.Filter(id)
+		if volume == "" && start == 0 {
+			// first play, measure it so it can be normalized next time
+			meter, err := player.Loudness.Measure(id, source)
 			if err != nil {
-				log.Println(err)
+				player.log().WithError(err).WithField("song", id).Warn("Starting loudness measurement failed")
+			} else {
+				player.meter = meter
+				source = meter
 			}
-			return
 		}
 	}
 
+	options.AudioFilter = player.Filters.Chain(volume, options.AudioFilter)
+
+	encoder, err := dca.EncodeMem(source, &options)
+	if err != nil {
+		player.abortMeasurement()
+		return nil, err
+	}
+
+	return encoder, nil
+}
+
+func (player *Player) abortMeasurement() {
+	if player.meter != nil {
+		player.meter.Abort()
+		player.meter = nil
+	}
 }
 
-func (player *Player) Purge() {
+func (player *Player) Purge() error {
 	player.Queue.Purge()
 
-	player.SendCommand(Stop)
+	return player.SendCommand(Quit)
 }
 
 func (player *Player) Stop() error {
-	player.Purge()
+	// the playing goroutine quits anyway, even if it missed the command
+	err := player.Purge()
+	if err != nil {
+		player.log().WithError(err).Warn("Stopping the current song failed")
+	}
 
 	player.QuitChannel <- true
 
@@ -561,7 +1000,7 @@ func (player *Player) Stop() error {
 		}
 	}
 
-	err := player.VoiceConnection.Disconnect()
+	err = player.VoiceConnection.Disconnect()
 	if err != nil {
 		return err
 	}
@@ -569,7 +1008,8 @@ func (player *Player) Stop() error {
 	return nil
 }
 
-func (player *Player) Add(item ...*QueueItem) {
+func (player *Player) Add(item ...*QueueItem) error {
+	item, rejected := player.CheckLimits(item...)
 	player.Queue.Add(item...)
 
 	if !player.IsPlaying {
@@ -578,10 +1018,95 @@ func (player *Player) Add(item ...*QueueItem) {
 			player.SongChannel <- song
 		}
 	}
+
+	return rejected
+}
+
+// Restore brings the queue back to an older state and starts playing if it's not already
+func (player *Player) Restore(items []*QueueItem, played func(item *QueueItem) bool) {
+	player.Queue.Restore(items, played)
+
+	if !player.IsPlaying {
+		song, err := player.Queue.GetFirst()
+		if err == nil {
+			player.SongChannel <- song
+		}
+	}
+}
+
+// AddNext puts items right after the currently playing song
+func (player *Player) AddNext(item ...*QueueItem) error {
+	if player.Queue.Len() == 0 {
+		return player.Add(item...)
+	}
+
+	item, rejected := player.CheckLimits(item...)
+	player.Queue.Insert(1, item...)
+
+	return rejected
+}
+
+// CurrentPosition asks the playing goroutine for the position in the current song, zero if nothing is playing
+func (player *Player) CurrentPosition() time.Duration {
+	if !player.IsPlaying {
+		return 0
+	}
+
+	// the song may be ending, so don't wait for the playing goroutine forever
+	timeout := time.After(time.Second)
+
+	select {
+	case player.CommandsChannel <- Position:
+	case <-timeout:
+		return 0
+	}
+
+	select {
+	case position := <-player.Position:
+		return position
+	case <-timeout:
+		return 0
+	}
+}
+
+// Seek restarts the current song from the given position
+func (player *Player) Seek(position time.Duration) error {
+	if !player.IsPlaying {
+		return errors.New("Nothing is playing")
+	}
+
+	song, err := player.Queue.GetFirst()
+	if err != nil {
+		return err
+	}
+
+	if position < 0 || (song.Info.Duration > 0 && position >= song.Info.Duration) {
+		return errors.New("Position is out of the song")
+	}
+
+	select {
+	case player.SeekChannel <- position:
+		return nil
+	case <-time.After(CommandTimeout):
+		return ErrPlayerNotResponding
+	}
 }
 
-func (player *Player) SendCommand(cmd PlayerCommand) {
-	if player.IsPlaying {
-		player.CommandsChannel <- cmd
+// Paused reports whether the current song is paused
+func (player *Player) Paused() bool {
+	return player.IsPlaying && player.Streamer != nil && player.Streamer.Paused()
+}
+
+// SendCommand does nothing when nothing is playing
+func (player *Player) SendCommand(cmd PlayerCommand) error {
+	if !player.IsPlaying {
+		return nil
+	}
+
+	select {
+	case player.CommandsChannel <- cmd:
+		return nil
+	case <-time.After(CommandTimeout):
+		return ErrPlayerNotResponding
 	}
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIndices(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []int
+		wantErr bool
+	}{
+		{[]string{"2"}, []int{1}, false},
+		{[]string{"2-4"}, []int{1, 2, 3}, false},
+		{[]string{"2,5"}, []int{1, 4}, false},
+		{[]string{"2,5-6,9"}, []int{1, 4, 5, 8}, false},
+		{[]string{"2", "5"}, []int{1, 4}, false},
+		{[]string{"2,", "5"}, []int{1, 4}, false},
+		{[]string{"1", "2", "3-4"}, []int{0, 1, 2, 3}, false},
+		{[]string{"4-2"}, nil, true},
+		{[]string{"1-2000"}, nil, true},
+		{[]string{"2-"}, nil, true},
+		{[]string{"2", "-", "4"}, nil, true},
+		{[]string{"never", "gonna"}, nil, true},
+		{[]string{","}, nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseIndices(strings.Join(test.args, ","))
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseIndices(%q) error = %v, want error %v", test.args, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseIndices(%q) = %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestTitleSearch(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{"title:never gonna", "never gonna", true},
+		{"title: ab", "ab", true},
+		{"\"never gonna\"", "never gonna", true},
+		{"title:", "", false},
+		{"\"\"", "", false},
+		{"ab", "", false},
+		{"\"ab", "", false},
+		{"2-4", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := titleSearch(test.text)
+		if got != test.want || ok != test.wantOk {
+			t.Errorf("titleSearch(%q) = %q, %v, want %q, %v", test.text, got, ok, test.want, test.wantOk)
+		}
+	}
+}
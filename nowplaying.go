@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	NowPlayingInterval = 10 * time.Second // how often the embed gets edited, keep it sane because of rate limits
+	ProgressBarWidth   = 20
+)
+
+// NowPlayingControls maps reactions on the now playing message to command names, the order is the order they're added in
+var NowPlayingControls = []struct {
+	Emoji   string
+	Command string
+}{
+	{"⏯", "pause"},
+	{"⏭", "skip"},
+	{"⏹", "stop"},
+	{"🔀", "shuffle"},
+	{"🔁", "loop"},
+}
+
+type NowPlaying struct {
+	sync.Mutex
+	session   *discordgo.Session
+	channelID string
+	messageID string
+	song      *QueueItem
+	position  time.Duration // as of the last update
+	paused    bool
+}
+
+func CreateNowPlaying(session *discordgo.Session, channelID string) *NowPlaying {
+	return &NowPlaying{
+		session:   session,
+		channelID: channelID,
+	}
+}
+
+// Start replaces the previous now playing message with a new one for the given song
+func (np *NowPlaying) Start(song *QueueItem) {
+	np.Lock()
+	defer np.Unlock()
+
+	np.delete()
+
+	np.song = song
+	np.position = 0
+	np.paused = false
+
+	np.post()
+}
+
+// SetChannel moves the message of the current song to another channel, following songs are posted there too
+func (np *NowPlaying) SetChannel(channelID string) {
+	np.Lock()
+	defer np.Unlock()
+
+	if channelID == np.channelID {
+		return
+	}
+
+	np.delete()
+	np.channelID = channelID
+
+	if np.song != nil {
+		np.post()
+	}
+}
+
+func (np *NowPlaying) post() {
+	msg, err := np.session.ChannelMessageSendEmbed(np.channelID, np.embed(np.position))
+	if err != nil {
+		logrus.WithError(err).WithField("channel", np.channelID).Warn("Sending now playing message failed")
+		return
+	}
+
+	np.messageID = msg.ID
+
+	for _, control := range NowPlayingControls {
+		err := np.session.MessageReactionAdd(np.channelID, np.messageID, control.Emoji)
+		if err != nil {
+			logrus.WithError(err).WithField("channel", np.channelID).Warn("Adding now playing control failed")
+		}
+	}
+}
+
+func (np *NowPlaying) MessageID() string {
+	np.Lock()
+	defer np.Unlock()
+
+	return np.messageID
+}
+
+func (np *NowPlaying) Update(position time.Duration, paused bool) {
+	np.Lock()
+	defer np.Unlock()
+
+	if np.messageID == "" || np.song == nil {
+		return
+	}
+
+	np.position = position
+	np.paused = paused
+
+	_, err := np.session.ChannelMessageEditEmbed(np.channelID, np.messageID, np.embed(position))
+	if err != nil {
+		logrus.WithError(err).WithField("channel", np.channelID).Warn("Updating now playing message failed")
+	}
+}
+
+// Clear removes the now playing message, used when there's nothing left to play
+func (np *NowPlaying) Clear() {
+	np.Lock()
+	defer np.Unlock()
+
+	np.delete()
+	np.song = nil
+}
+
+func (np *NowPlaying) delete() {
+	if np.messageID == "" {
+		return
+	}
+
+	err := np.session.ChannelMessageDelete(np.channelID, np.messageID)
+	if err != nil {
+		logrus.WithError(err).WithField("channel", np.channelID).Warn("Deleting now playing message failed")
+	}
+
+	np.messageID = ""
+}
+
+func (np *NowPlaying) embed(position time.Duration) *discordgo.MessageEmbed {
+	state := "Now playing"
+	if np.paused {
+		state = "Paused"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       np.song.Info.Title,
+		URL:         np.song.Info.Link,
+		Description: ProgressBar(position, np.song.Info.Duration, ProgressBarWidth),
+		Author: &discordgo.MessageEmbedAuthor{
+			Name: state,
+		},
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Requested by:",
+				Value:  np.song.RequestedBy,
+				Inline: true,
+			},
+			{
+				Name:   "Length:",
+				Value:  FormatDuration(np.song.Info.Duration),
+				Inline: true,
+			},
+		},
+	}
+
+	if np.song.Info.Thumbnail != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
+			URL: np.song.Info.Thumbnail,
+		}
+	}
+
+	return embed
+}
+
+func ProgressBar(position time.Duration, duration time.Duration, width int) string {
+	var filled int
+	if duration > 0 {
+		filled = int(int64(width) * int64(position) / int64(duration))
+	}
+
+	if filled > width-1 {
+		filled = width - 1
+	} else if filled < 0 {
+		filled = 0
+	}
+
+	bar := strings.Repeat("▬", filled) + "🔘" + strings.Repeat("▬", width-filled-1)
+
+	return fmt.Sprintf("%s `%s / %s`", bar, FormatDuration(position), FormatDuration(duration))
+}
+
+// ParseDuration parses positions like 1:23, 1:02:03 or 83 (seconds)
+func ParseDuration(text string) (time.Duration, error) {
+	var seconds int
+
+	parts := strings.Split(text, ":")
+	if len(parts) > 3 {
+		return 0, errors.New("Invalid time, use e.g. 1:23")
+	}
+
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, errors.New("Invalid time, use e.g. 1:23")
+		}
+
+		seconds = seconds*60 + n
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		return "-" + FormatDuration(-d)
+	}
+
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := int(d % time.Minute / time.Second)
+
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+
+	return fmt.Sprintf("%d:%02d", minutes, seconds)
+}
+
+// ProcessReaction runs the command bound to a reaction on the now playing message, with the same permission checks as text commands,
+// message IDs are unique so reactions are matched to the bot's messages in any channel
+func (bot *Bot) ProcessReaction(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	if r.UserID == s.State.User.ID {
+		return
+	}
+
+	if bot.Player == nil {
+		return
+	}
+
+	if r.MessageID == bot.Player.QueueView.MessageID() {
+		bot.ProcessQueueViewReaction(s, r)
+		return
+	}
+
+	if r.MessageID != bot.Player.NowPlaying.MessageID() {
+		return
+	}
+
+	var cmd *CommandConstructor
+	for _, control := range NowPlayingControls {
+		if control.Emoji == r.Emoji.Name {
+			cmd = bot.Commands.ByName[control.Command]
+		}
+	}
+
+	// let the reaction be used again
+	s.MessageReactionRemove(r.ChannelID, r.MessageID, r.Emoji.APIName(), r.UserID)
+
+	if cmd == nil {
+		return
+	}
+
+	user, err := s.User(r.UserID)
+	if err != nil {
+		logrus.WithError(err).WithField("user", r.UserID).Warn("Looking up reacting user failed")
+		return
+	}
+
+	if settings := bot.Config().Channel(r.ChannelID); settings == nil || !settings.Allows(cmd) {
+		s.ChannelMessageSend(r.ChannelID, "This command can't be used in this channel")
+		return
+	}
+
+	if !bot.HasPermission(user.ID, cmd) {
+		countDenial(cmd)
+		s.ChannelMessageSend(r.ChannelID, ErrPermissionDenied.Error())
+		return
+	}
+
+	m := &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ChannelID: r.ChannelID,
+			Author:    user,
+		},
+	}
+
+	err = bot.RunCommand(cmd, nil, m, s)
+	if err != nil {
+		bot.commandLog(m).WithError(err).WithField("command", cmd.Names[0]).Info("Command failed")
+		s.ChannelMessageSend(r.ChannelID, "Error: "+err.Error())
+	}
+}
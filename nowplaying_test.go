@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"83", 83 * time.Second, false},
+		{"1:23", 83 * time.Second, false},
+		{"01:05", 65 * time.Second, false},
+		{"1:02:03", time.Hour + 2*time.Minute + 3*time.Second, false},
+		{"0:90", 90 * time.Second, false},
+		{"", 0, true},
+		{"1:", 0, true},
+		{":30", 0, true},
+		{"1:-5", 0, true},
+		{"1.5", 0, true},
+		{"abc", 0, true},
+		{"1:00:00:00", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseDuration(test.text)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseDuration(%q) error = %v, want error %v", test.text, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0:00"},
+		{5 * time.Second, "0:05"},
+		{83 * time.Second, "1:23"},
+		{1500 * time.Millisecond, "0:02"},
+		{-83 * time.Second, "-1:23"},
+		{59*time.Minute + 59*time.Second, "59:59"},
+		{time.Hour, "1:00:00"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "1:02:03"},
+		{25 * time.Hour, "25:00:00"},
+	}
+
+	for _, test := range tests {
+		if got := FormatDuration(test.d); got != test.want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", test.d, got, test.want)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		position time.Duration
+		duration time.Duration
+		width    int
+		want     string
+	}{
+		{0, 10 * time.Second, 5, "🔘▬▬▬▬ `0:00 / 0:10`"},
+		{4 * time.Second, 10 * time.Second, 5, "▬▬🔘▬▬ `0:04 / 0:10`"},
+		{10 * time.Second, 10 * time.Second, 5, "▬▬▬▬🔘 `0:10 / 0:10`"},
+		{20 * time.Second, 10 * time.Second, 5, "▬▬▬▬🔘 `0:20 / 0:10`"},
+		{-time.Second, 10 * time.Second, 5, "🔘▬▬▬▬ `-0:01 / 0:10`"},
+		{3 * time.Second, 0, 5, "🔘▬▬▬▬ `0:03 / 0:00`"}, // live streams have no duration
+	}
+
+	for _, test := range tests {
+		if got := ProgressBar(test.position, test.duration, test.width); got != test.want {
+			t.Errorf("ProgressBar(%v, %v, %d) = %q, want %q", test.position, test.duration, test.width, got, test.want)
+		}
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/bwmarrin/discordgo"
-	"io"
-	"log"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
 	"os"
+	"reflect"
 	"sync"
 )
 
+const PermissionsFile = "permissions.json"
+
 type UserPermissions map[string]bool
 type Permissions map[string]UserPermissions
 
@@ -21,21 +24,16 @@ type PermissionsManager struct {
 }
 
 func (cmds *Commands) InitPermissions(filePath string) *PermissionsManager {
-	pm := PermissionsManager{
-		filepath:    filePath,
-		permissions: make(Permissions),
-	}
-
-	f, err := os.OpenFile(filePath, os.O_RDONLY|os.O_CREATE, 0755)
+	permissions, err := LoadPermissions(filePath)
 	if err != nil {
-		log.Println(err)
+		logrus.WithError(err).Error("Loading permissions failed")
+		permissions = make(Permissions)
 	}
 
-	buf := bytes.NewBuffer(nil)
-	io.Copy(buf, f)
-	f.Close()
-
-	json.Unmarshal(buf.Bytes(), pm.permissions)
+	pm := PermissionsManager{
+		filepath:    filePath,
+		permissions: permissions,
+	}
 
 	setperm := CommandConstructor{
 		Names:             []string{"setperm"},
@@ -70,6 +68,29 @@ func (cmds *Commands) InitPermissions(filePath string) *PermissionsManager {
 	return &pm
 }
 
+// LoadPermissions reads the permissions file, a missing one means nobody has any permissions set
+func LoadPermissions(filePath string) (Permissions, error) {
+	permissions := make(Permissions)
+
+	file, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return permissions, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(file)) == 0 {
+		return permissions, nil
+	}
+
+	err = json.Unmarshal(file, &permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
 /*func (perm Permissions) Load() {
 	file, err := ioutil.ReadFile(filePath)
 	if err != nil {
@@ -88,18 +109,31 @@ func (perm *PermissionsManager) Save() {
 
 	text, err := json.Marshal(perm.permissions)
 	if err != nil {
-		log.Print(err)
+		logrus.WithError(err).Error("Saving permissions failed")
 	}
 
-	f, err := os.OpenFile(perm.filepath, os.O_WRONLY|os.O_TRUNC, 0755)
+	f, err := os.OpenFile(perm.filepath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
 	if err != nil {
-		log.Print(err)
+		logrus.WithError(err).Error("Saving permissions failed")
 	}
 
 	f.Write(text)
 	f.Close()
 }
 
+// Replace swaps all permissions, e.g. after the file was edited by hand, and tells whether anything changed
+func (perm *PermissionsManager) Replace(permissions Permissions) bool {
+	perm.Lock()
+	defer perm.Unlock()
+
+	if reflect.DeepEqual(perm.permissions, permissions) {
+		return false
+	}
+
+	perm.permissions = permissions
+	return true
+}
+
 func (perm *PermissionsManager) Set(userID string, key string, value bool, commandDefault bool) error {
 	perm.Lock()
 	defer perm.Unlock()
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPlaylistNotFound error = errors.New("No such playlist")
+	ErrPlaylistExists   error = errors.New("Playlist with this name already exists")
+	ErrNotPlaylistOwner error = errors.New("Only the owner or an admin can modify this playlist")
+)
+
+type SavedItem struct {
+	Link     string
+	Title    string
+	Duration time.Duration
+}
+
+type SavedPlaylist struct {
+	Name      string
+	Owner     string // user ID
+	OwnerName string
+	Shared    bool // shared playlists can be loaded by anyone in the guild
+	Created   time.Time
+	Items     []SavedItem
+}
+
+// UserPlaylists maps playlist name to the playlist
+type UserPlaylists map[string]*SavedPlaylist
+
+// Playlists maps user ID to their playlists
+type Playlists map[string]UserPlaylists
+
+type PlaylistStore struct {
+	sync.RWMutex
+	filepath  string
+	playlists Playlists
+}
+
+func (cmds *Commands) InitPlaylists(filePath string) *PlaylistStore {
+	ps := PlaylistStore{
+		filepath:  filePath,
+		playlists: make(Playlists),
+	}
+
+	file, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).Error("Loading playlists failed")
+	}
+	if file != nil {
+		json.Unmarshal(file, &ps.playlists)
+	}
+
+	// not a command, only a permission to let users modify playlists of others
+	managePlaylists := CommandConstructor{
+		Permission:        "managePlaylists",
+		DefaultPermission: false,
+	}
+
+	cmds.RegisterCommands(&managePlaylists)
+
+	return &ps
+}
+
+func (ps *PlaylistStore) Save() {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	text, err := json.Marshal(ps.playlists)
+	if err != nil {
+		logrus.WithError(err).Error("Saving playlists failed")
+		return
+	}
+
+	err = ioutil.WriteFile(ps.filepath, text, 0644)
+	if err != nil {
+		logrus.WithError(err).Error("Saving playlists failed")
+	}
+}
+
+func (ps *PlaylistStore) Create(playlist *SavedPlaylist) error {
+	ps.Lock()
+
+	if ps.playlists[playlist.Owner] == nil {
+		ps.playlists[playlist.Owner] = make(UserPlaylists)
+	}
+
+	if ps.playlists[playlist.Owner][playlist.Name] != nil {
+		ps.Unlock()
+		return ErrPlaylistExists
+	}
+
+	ps.playlists[playlist.Owner][playlist.Name] = playlist
+	ps.Unlock()
+
+	ps.Save()
+
+	return nil
+}
+
+// Find looks up user's own playlist first, then playlists shared by others
+func (ps *PlaylistStore) Find(userID string, name string) (*SavedPlaylist, error) {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	return ps.find(userID, name)
+}
+
+func (ps *PlaylistStore) find(userID string, name string) (*SavedPlaylist, error) {
+	if playlist := ps.playlists[userID][name]; playlist != nil {
+		return playlist, nil
+	}
+
+	for _, user := range ps.playlists {
+		if playlist := user[name]; playlist != nil && playlist.Shared {
+			return playlist, nil
+		}
+	}
+
+	return nil, ErrPlaylistNotFound
+}
+
+// findOwned looks up a playlist given as <owner>/<name>, the owner is a user ID or the name of the user
+func (ps *PlaylistStore) findOwned(ref string) (*SavedPlaylist, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 {
+		return nil, ErrPlaylistNotFound
+	}
+
+	for ownerID, user := range ps.playlists {
+		playlist := user[parts[1]]
+		if playlist != nil && (ownerID == parts[0] || strings.EqualFold(playlist.OwnerName, parts[0])) {
+			return playlist, nil
+		}
+	}
+
+	return nil, ErrPlaylistNotFound
+}
+
+// List returns user's own playlists and playlists shared by others, admins get all of them
+func (ps *PlaylistStore) List(userID string, admin bool) []*SavedPlaylist {
+	ps.RLock()
+	defer ps.RUnlock()
+
+	var list []*SavedPlaylist
+	for owner, user := range ps.playlists {
+		for _, playlist := range user {
+			if owner == userID || playlist.Shared || admin {
+				list = append(list, playlist)
+			}
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// Modify runs the function on a playlist found by name, if the user is allowed to modify it,
+// admins can give private playlists of others as <owner>/<name>
+func (ps *PlaylistStore) Modify(userID string, name string, admin bool, modify func(playlist *SavedPlaylist) error) error {
+	ps.Lock()
+
+	playlist, err := ps.find(userID, name)
+	if err == ErrPlaylistNotFound && admin {
+		playlist, err = ps.findOwned(name)
+	}
+	if err != nil {
+		ps.Unlock()
+		return err
+	}
+
+	if playlist.Owner != userID && !admin {
+		ps.Unlock()
+		return ErrNotPlaylistOwner
+	}
+
+	err = modify(playlist)
+	ps.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	ps.Save()
+
+	return nil
+}
+
+func (ps *PlaylistStore) Delete(userID string, name string, admin bool) error {
+	return ps.Modify(userID, name, admin, func(playlist *SavedPlaylist) error {
+		delete(ps.playlists[playlist.Owner], playlist.Name)
+		return nil
+	})
+}
+
+func (ps *PlaylistStore) Rename(userID string, name string, newName string, admin bool) error {
+	return ps.Modify(userID, name, admin, func(playlist *SavedPlaylist) error {
+		if ps.playlists[playlist.Owner][newName] != nil {
+			return ErrPlaylistExists
+		}
+
+		delete(ps.playlists[playlist.Owner], playlist.Name)
+		playlist.Name = newName
+		ps.playlists[playlist.Owner][newName] = playlist
+
+		return nil
+	})
+}
+
+// RunPlaylistSubcommand handles !playlist save/load/list/delete/rename/share
+func RunPlaylistSubcommand(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+	admin := bot.HasPermission(m.Author.ID, bot.Commands.ByPermission["managePlaylists"])
+
+	switch raw[0] {
+	case "save":
+		if len(raw) != 2 {
+			return errors.New("Usage: !playlist save <name>")
+		}
+
+		if bot.Player == nil {
+			return ErrPlayerNotConnected
+		}
+
+		queue, err := bot.Player.Queue.GetAll()
+		if err != nil {
+			return err
+		}
+
+		playlist := &SavedPlaylist{
+			Name:      raw[1],
+			Owner:     m.Author.ID,
+			OwnerName: m.Author.Username,
+			Created:   time.Now(),
+		}
+
+		for _, item := range queue {
+			playlist.Items = append(playlist.Items, SavedItem{
+				Link:     item.Info.Link,
+				Title:    item.Info.Title,
+				Duration: item.Info.Duration,
+			})
+		}
+
+		err = bot.Playlists.Create(playlist)
+		if err != nil {
+			return err
+		}
+
+		s.ChannelMessageSend(m.ChannelID, "Playlist saved with "+strconv.Itoa(len(playlist.Items))+" songs")
+	case "load":
+		if len(raw) != 2 {
+			return errors.New("Usage: !playlist load <name>")
+		}
+
+		if bot.Player == nil {
+			return ErrPlayerNotConnected
+		}
+
+		playlist, err := bot.Playlists.Find(m.Author.ID, raw[1])
+		if err != nil {
+			return err
+		}
+
+		var rejected error
+		for _, saved := range playlist.Items {
+			item, err := CreateQueueItem(saved.Link, m.Author, bot.Blocklist)
+			if err != nil {
+				bot.commandLog(m).WithError(err).WithField("link", saved.Link).Warn("Loading playlist item failed")
+				continue
+			}
+
+			if err := bot.Player.Add(item); err != nil {
+				rejected = err
+			}
+		}
+
+		return rejected
+	case "list":
+		playlists := bot.Playlists.List(m.Author.ID, admin)
+		if len(playlists) == 0 {
+			return errors.New("No saved playlists")
+		}
+
+		var formatedList string
+		for _, playlist := range playlists {
+			formatedList = strings.Join([]string{formatedList, playlist.Name, " (", strconv.Itoa(len(playlist.Items)), " songs, by ", playlist.OwnerName, ")"}, "")
+			if playlist.Shared {
+				formatedList += " [shared]"
+			} else if playlist.Owner != m.Author.ID {
+				formatedList += " [private]"
+			}
+			formatedList += "\n"
+		}
+
+		s.ChannelMessageSend(m.ChannelID, formatedList)
+	case "delete":
+		if len(raw) != 2 {
+			return errors.New("Usage: !playlist delete [<owner>/]<name>")
+		}
+
+		err := bot.Playlists.Delete(m.Author.ID, raw[1], admin)
+		if err != nil {
+			return err
+		}
+
+		s.ChannelMessageSend(m.ChannelID, "Playlist deleted")
+	case "rename":
+		if len(raw) != 3 {
+			return errors.New("Usage: !playlist rename [<owner>/]<name> <new name>")
+		}
+
+		err := bot.Playlists.Rename(m.Author.ID, raw[1], raw[2], admin)
+		if err != nil {
+			return err
+		}
+
+		s.ChannelMessageSend(m.ChannelID, "Playlist renamed")
+	case "share":
+		if len(raw) != 2 {
+			return errors.New("Usage: !playlist share [<owner>/]<name>")
+		}
+
+		var shared bool
+		err := bot.Playlists.Modify(m.Author.ID, raw[1], admin, func(playlist *SavedPlaylist) error {
+			playlist.Shared = !playlist.Shared
+			shared = playlist.Shared
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if shared {
+			s.ChannelMessageSend(m.ChannelID, "Playlist is now shared")
+		} else {
+			s.ChannelMessageSend(m.ChannelID, "Playlist is no longer shared")
+		}
+	default:
+		return errors.New("Unknown subcommand, use save, load, list, delete, rename or share")
+	}
+
+	return nil
+}
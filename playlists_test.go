@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPlaylistStoreDelete(t *testing.T) {
+	tests := []struct {
+		userID  string
+		name    string
+		admin   bool
+		wantErr error
+	}{
+		{"owner", "mix", false, nil},
+		{"other", "mix", false, ErrPlaylistNotFound},
+		{"other", "shared", false, ErrNotPlaylistOwner},
+		{"other", "shared", true, nil},
+		{"admin", "owner/mix", true, nil},
+		{"admin", "Alice/mix", true, nil},
+		{"admin", "owner/mix", false, ErrPlaylistNotFound},
+		{"admin", "nobody/mix", true, ErrPlaylistNotFound},
+		{"admin", "owner/missing", true, ErrPlaylistNotFound},
+	}
+
+	for _, test := range tests {
+		ps := &PlaylistStore{
+			filepath: filepath.Join(t.TempDir(), "playlists.json"),
+			playlists: Playlists{
+				"owner": UserPlaylists{
+					"mix":    {Name: "mix", Owner: "owner", OwnerName: "alice"},
+					"shared": {Name: "shared", Owner: "owner", OwnerName: "alice", Shared: true},
+				},
+			},
+		}
+
+		err := ps.Delete(test.userID, test.name, test.admin)
+		if err != test.wantErr {
+			t.Errorf("Delete(%q, %q, %v) error = %v, want %v", test.userID, test.name, test.admin, err, test.wantErr)
+		}
+	}
+}
+
+func TestPlaylistStoreList(t *testing.T) {
+	ps := &PlaylistStore{
+		playlists: Playlists{
+			"owner": UserPlaylists{
+				"mix":    {Name: "mix", Owner: "owner"},
+				"shared": {Name: "shared", Owner: "owner", Shared: true},
+			},
+		},
+	}
+
+	tests := []struct {
+		userID string
+		admin  bool
+		want   int
+	}{
+		{"owner", false, 2},
+		{"other", false, 1},
+		{"other", true, 2},
+	}
+
+	for _, test := range tests {
+		if got := len(ps.List(test.userID, test.admin)); got != test.want {
+			t.Errorf("List(%q, %v) has %d playlists, want %d", test.userID, test.admin, got, test.want)
+		}
+	}
+}
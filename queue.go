@@ -4,12 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Queue struct {
 	sync.RWMutex
-	queue []*QueueItem
+	queue  []*QueueItem
+	lastID int
+	Events *EventBus // QueueChanged is published after every change, outside of the lock
 }
 
 type Playable interface {
@@ -19,15 +25,20 @@ type Playable interface {
 }
 
 type ItemInfo struct {
-	Title    string
-	Link     string
-	Duration string
+	ID        string // unique across sources, e.g. youtube:<video id>
+	Title     string
+	Link      string
+	Thumbnail string
+	Duration  time.Duration
 }
 
 type QueueItem struct {
+	ID          string // stable while the item is queued, assigned by the queue
 	Stream      Playable
 	Info        ItemInfo
 	RequestedBy string
+	RequesterID string
+	AutoQueued  bool // picked by autoplay, not by a user
 }
 
 type ErrItemNotFound struct {
@@ -38,20 +49,115 @@ func (err ErrItemNotFound) Error() string {
 	return fmt.Sprintf("Item number %d not found", err.item+1)
 }
 
+var ErrCurrentItem error = errors.New("Cannot remove or move currently playing song")
+
+func (q *Queue) changed() {
+	q.Events.Publish(QueueChanged{})
+}
+
+// assignIDs gives items short IDs made of letters, so they can't be confused with positions
+func (q *Queue) assignIDs(items []*QueueItem) {
+	for _, item := range items {
+		q.lastID++
+
+		id := ""
+		for n := q.lastID; n > 0; n = (n - 1) / 26 {
+			id = string(rune('a'+(n-1)%26)) + id
+		}
+
+		item.ID = id
+	}
+}
+
+// position resolves a reference given by user, either an item ID or a 1-indexed position, into a 0-indexed position
+func (q *Queue) position(ref string) (int, error) {
+	ref = strings.TrimPrefix(strings.ToLower(ref), "#")
+
+	for i, item := range q.queue {
+		if item.ID == ref {
+			return i, nil
+		}
+	}
+
+	i, err := strconv.Atoi(ref)
+	if err != nil {
+		return 0, fmt.Errorf("No item with ID %s", ref)
+	}
+
+	return i - 1, nil // slices are 0-index, but appears as 1-indexed to the user
+}
+
+// IsItemID checks whether the reference looks like an item ID, whether or not it's still queued
+func IsItemID(ref string) bool {
+	ref = strings.TrimPrefix(ref, "#")
+	if ref == "" {
+		return false
+	}
+
+	for _, r := range ref {
+		if (r < 'a' || 'z' < r) && (r < 'A' || 'Z' < r) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (q *Queue) Add(items ...*QueueItem) {
+	defer q.changed()
 	q.Lock()
 	defer q.Unlock()
 
+	q.assignIDs(items)
 	q.queue = append(q.queue, items...)
 }
 
+// Insert puts items at the given position, or at the end if the queue is shorter
+func (q *Queue) Insert(i int, items ...*QueueItem) {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	q.assignIDs(items)
+
+	if i >= len(q.queue) {
+		q.queue = append(q.queue, items...)
+		return
+	}
+
+	q.queue = append(q.queue[:i], append(append([]*QueueItem{}, items...), q.queue[i:]...)...)
+}
+
 func (q *Queue) Remove(i int) error {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	return q.remove(i)
+}
+
+// RemoveRef removes an item by its ID or position, the currently playing one can't be removed
+func (q *Queue) RemoveRef(ref string) error {
+	defer q.changed()
 	q.Lock()
 	defer q.Unlock()
 
+	i, err := q.position(ref)
+	if err != nil {
+		return err
+	}
+
+	if i == 0 {
+		return ErrCurrentItem
+	}
+
+	return q.remove(i)
+}
+
+func (q *Queue) remove(i int) error {
 	if len(q.queue) == 0 {
 		return errors.New("Queue is empty")
-	} else if len(q.queue) <= i {
+	} else if i < 0 || len(q.queue) <= i {
 		return ErrItemNotFound{i}
 	} else if len(q.queue) > 1 {
 		copy(q.queue[i:], q.queue[i+1:])
@@ -64,13 +170,143 @@ func (q *Queue) Remove(i int) error {
 	return nil
 }
 
+// RemoveWhere removes all matching items in one go, except the first (currently playing) one, returns number of removed items
+func (q *Queue) RemoveWhere(match func(i int, item *QueueItem) bool) int {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.queue) == 0 {
+		return 0
+	}
+
+	kept := q.queue[:1]
+	for i, item := range q.queue[1:] {
+		if !match(i+1, item) {
+			kept = append(kept, item)
+		}
+	}
+
+	removed := len(q.queue) - len(kept)
+	for i := len(kept); i < len(q.queue); i++ {
+		q.queue[i] = nil
+	}
+	q.queue = kept
+
+	return removed
+}
+
+func (q *Queue) RemoveIndices(indices ...int) int {
+	remove := make(map[int]bool)
+	for _, i := range indices {
+		remove[i] = true
+	}
+
+	return q.RemoveWhere(func(i int, item *QueueItem) bool {
+		return remove[i]
+	})
+}
+
+func (q *Queue) RemoveRequestedBy(userID string) int {
+	return q.RemoveWhere(func(i int, item *QueueItem) bool {
+		return item.RequesterID == userID
+	})
+}
+
+// RemoveByTitle removes items with titles containing the given string, case insensitive
+func (q *Queue) RemoveByTitle(title string) int {
+	title = strings.ToLower(title)
+
+	return q.RemoveWhere(func(i int, item *QueueItem) bool {
+		return strings.Contains(strings.ToLower(item.Info.Title), title)
+	})
+}
+
 func (q *Queue) Purge() {
+	defer q.changed()
 	q.Lock()
 	defer q.Unlock()
 
 	q.queue = nil
 }
 
+// Shuffle randomizes the order of the queue, except for the first (currently playing) item
+func (q *Queue) Shuffle() error {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.queue) < 3 {
+		return errors.New("Not enough items to shuffle")
+	}
+
+	rand.Shuffle(len(q.queue)-1, func(i, j int) {
+		q.queue[i+1], q.queue[j+1] = q.queue[j+1], q.queue[i+1]
+	})
+
+	return nil
+}
+
+// Dedupe removes songs which are already in the queue, the earliest one is kept, returns number of removed songs
+func (q *Queue) Dedupe() int {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	seen := make(map[string]bool)
+	deduped := q.queue[:0]
+
+	for _, item := range q.queue {
+		if seen[item.Info.ID] {
+			continue
+		}
+
+		seen[item.Info.ID] = true
+		deduped = append(deduped, item)
+	}
+
+	removed := len(q.queue) - len(deduped)
+	for i := len(deduped); i < len(q.queue); i++ {
+		q.queue[i] = nil
+	}
+	q.queue = deduped
+
+	return removed
+}
+
+// Restore brings the queue back to an older state, the currently playing song stays first and songs filtered by played aren't restored
+func (q *Queue) Restore(items []*QueueItem, played func(item *QueueItem) bool) {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	var restored []*QueueItem
+	if len(q.queue) > 0 {
+		restored = append(restored, q.queue[0])
+	}
+
+	for _, item := range items {
+		if len(q.queue) > 0 && item == q.queue[0] {
+			continue
+		}
+
+		if played(item) {
+			continue
+		}
+
+		restored = append(restored, item)
+	}
+
+	q.queue = restored
+}
+
+func (q *Queue) Len() int {
+	q.RLock()
+	defer q.RUnlock()
+
+	return len(q.queue)
+}
+
 func (q *Queue) GetFirst() (*QueueItem, error) {
 	q.RLock()
 	defer q.RUnlock()
@@ -86,7 +322,24 @@ func (q *Queue) Get(i int) (*QueueItem, error) {
 	q.RLock()
 	defer q.RUnlock()
 
-	if len(q.queue) <= i {
+	return q.get(i)
+}
+
+// GetRef returns an item by its ID or position
+func (q *Queue) GetRef(ref string) (*QueueItem, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	i, err := q.position(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.get(i)
+}
+
+func (q *Queue) get(i int) (*QueueItem, error) {
+	if i < 0 || len(q.queue) <= i {
 		return nil, errors.New("Item doesn't exist")
 	}
 
@@ -125,32 +378,80 @@ func (q *Queue) GetAll() ([]*QueueItem, error) {
 		return nil, errors.New("Queue is empty")
 	}
 
-	var queueCopy []*QueueItem
+	queueCopy := make([]*QueueItem, len(q.queue))
 	copy(queueCopy, q.queue)
 
 	return queueCopy, nil
 }
 
 func (q *Queue) Move(from int, to int) error {
+	defer q.changed()
 	q.Lock()
 	defer q.Unlock()
 
+	return q.move(from, to)
+}
+
+// MoveRef moves an item given by its ID or position, the currently playing song can't be moved by users
+func (q *Queue) MoveRef(ref string, to int) error {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	from, err := q.position(ref)
+	if err != nil {
+		return err
+	} else if from == 0 && len(q.queue) > 0 {
+		return ErrCurrentItem
+	}
+
+	return q.move(from, to)
+}
+
+// JumpRef brings an item given by its ID or position right after the current one, songs in between are dropped unless keep is set
+func (q *Queue) JumpRef(ref string, keep bool) error {
+	defer q.changed()
+	q.Lock()
+	defer q.Unlock()
+
+	i, err := q.position(ref)
+	if err != nil {
+		return err
+	}
+
+	if i < 0 || len(q.queue) <= i {
+		return ErrItemNotFound{i}
+	} else if i == 0 {
+		return errors.New("Song is already playing")
+	}
+
+	if keep {
+		return q.move(i, 1)
+	}
+
+	for j := 1; j < i; j++ {
+		q.queue[j] = nil
+	}
+	q.queue = append(q.queue[:1], q.queue[i:]...)
+
+	return nil
+}
+
+// move doesn't put anything in front of the current song, but the current song itself can be moved back when looping
+func (q *Queue) move(from int, to int) error {
 	if len(q.queue) == 0 {
 		return errors.New("Queue is empty")
-	} else if len(q.queue) <= from {
+	} else if from < 0 || len(q.queue) <= from {
 		return ErrItemNotFound{from}
 	} else if from == to {
 		return nil
+	} else if to < 1 || len(q.queue) <= to {
+		return errors.New("Position is out of the queue")
 	}
 
 	item := q.queue[from]
 	q.queue = append(q.queue[:from], q.queue[from+1:]...)
-
-	if to == 0 {
-		q.queue = append([]*QueueItem{item}, q.queue...)
-	} else {
-		q.queue = append(q.queue[:to], append([]*QueueItem{item}, q.queue[to:]...)...)
-	}
+	q.queue = append(q.queue[:to], append([]*QueueItem{item}, q.queue[to:]...)...)
 
 	return nil
 }
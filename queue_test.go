@@ -0,0 +1,229 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testQueue makes a queue of items with the given info IDs, queue IDs are assigned like when adding
+func testQueue(ids ...string) *Queue {
+	q := &Queue{}
+	q.Add(testItems(ids...)...)
+
+	return q
+}
+
+func testItems(ids ...string) []*QueueItem {
+	items := make([]*QueueItem, len(ids))
+	for i, id := range ids {
+		items[i] = &QueueItem{Info: ItemInfo{ID: id, Title: id}}
+	}
+
+	return items
+}
+
+// infoIDs lists info IDs of the queued items in order
+func infoIDs(q *Queue) []string {
+	var ids []string
+	for _, item := range q.queue {
+		ids = append(ids, item.Info.ID)
+	}
+
+	return ids
+}
+
+func TestQueueInsert(t *testing.T) {
+	tests := []struct {
+		queue []string
+		at    int
+		items []string
+		want  []string
+	}{
+		{nil, 0, []string{"x"}, []string{"x"}},
+		{[]string{"a", "b", "c"}, 1, []string{"x"}, []string{"a", "x", "b", "c"}},
+		{[]string{"a", "b", "c"}, 1, []string{"x", "y"}, []string{"a", "x", "y", "b", "c"}},
+		{[]string{"a", "b", "c"}, 3, []string{"x"}, []string{"a", "b", "c", "x"}},
+		{[]string{"a", "b"}, 10, []string{"x"}, []string{"a", "b", "x"}},
+	}
+
+	for _, test := range tests {
+		q := testQueue(test.queue...)
+		q.Insert(test.at, testItems(test.items...)...)
+
+		if got := infoIDs(q); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Insert(%d, %v) into %v = %v, want %v", test.at, test.items, test.queue, got, test.want)
+		}
+	}
+}
+
+func TestQueueInsertAssignsIDs(t *testing.T) {
+	q := testQueue("a", "b")
+	q.Insert(1, testItems("x")...)
+
+	var ids []string
+	for _, item := range q.queue {
+		ids = append(ids, item.ID)
+	}
+
+	if want := []string{"a", "c", "b"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("IDs after Insert = %v, want %v", ids, want)
+	}
+}
+
+func TestQueueDedupe(t *testing.T) {
+	tests := []struct {
+		queue   []string
+		want    []string
+		removed int
+	}{
+		{nil, nil, 0},
+		{[]string{"a", "b", "c"}, []string{"a", "b", "c"}, 0},
+		{[]string{"a", "b", "a"}, []string{"a", "b"}, 1},
+		{[]string{"a", "b", "b", "c", "b", "a"}, []string{"a", "b", "c"}, 3},
+		{[]string{"a", "a", "a"}, []string{"a"}, 2},
+	}
+
+	for _, test := range tests {
+		q := testQueue(test.queue...)
+		removed := q.Dedupe()
+
+		if got := infoIDs(q); !reflect.DeepEqual(got, test.want) || removed != test.removed {
+			t.Errorf("Dedupe() of %v = %v removing %d, want %v removing %d", test.queue, got, removed, test.want, test.removed)
+		}
+	}
+}
+
+func TestQueueMove(t *testing.T) {
+	tests := []struct {
+		queue   []string
+		from    int
+		to      int
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"a", "b", "c", "d"}, 3, 1, []string{"a", "d", "b", "c"}, false},
+		{[]string{"a", "b", "c", "d"}, 1, 3, []string{"a", "c", "d", "b"}, false},
+		{[]string{"a", "b", "c", "d"}, 2, 2, []string{"a", "b", "c", "d"}, false},
+		{[]string{"a", "b", "c", "d"}, 0, 3, []string{"b", "c", "d", "a"}, false}, // looping moves the current song back
+		{[]string{"a", "b", "c", "d"}, 2, 0, []string{"a", "b", "c", "d"}, true},
+		{[]string{"a", "b", "c", "d"}, 2, -1, []string{"a", "b", "c", "d"}, true},
+		{[]string{"a", "b", "c", "d"}, 2, 4, []string{"a", "b", "c", "d"}, true},
+		{[]string{"a", "b", "c", "d"}, 4, 1, []string{"a", "b", "c", "d"}, true},
+		{[]string{"a", "b", "c", "d"}, -1, 1, []string{"a", "b", "c", "d"}, true},
+		{nil, 1, 1, nil, true},
+	}
+
+	for _, test := range tests {
+		q := testQueue(test.queue...)
+		err := q.Move(test.from, test.to)
+
+		if (err != nil) != test.wantErr {
+			t.Errorf("Move(%d, %d) in %v error = %v, want error %v", test.from, test.to, test.queue, err, test.wantErr)
+		}
+		if got := infoIDs(q); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Move(%d, %d) in %v = %v, want %v", test.from, test.to, test.queue, got, test.want)
+		}
+	}
+}
+
+func TestQueueRestore(t *testing.T) {
+	tests := []struct {
+		queue  []string // current state, the first one is playing
+		before []string // older state, songs not in the current one were removed since
+		played []string
+		want   []string
+	}{
+		{[]string{"a", "b", "c"}, []string{"a", "c", "b"}, nil, []string{"a", "c", "b"}},
+		{[]string{"a", "b"}, []string{"a", "b", "x"}, nil, []string{"a", "b", "x"}},
+		{[]string{"b"}, []string{"a", "b", "c"}, []string{"a"}, []string{"b", "c"}},
+		{[]string{"a", "b", "c"}, []string{"b", "c"}, nil, []string{"a", "b", "c"}},
+		{nil, []string{"x"}, nil, []string{"x"}},
+	}
+
+	for _, test := range tests {
+		q := testQueue(test.queue...)
+
+		// restored items are the same ones which were queued before, not copies
+		items := make(map[string]*QueueItem)
+		for _, item := range q.queue {
+			items[item.Info.ID] = item
+		}
+
+		var before []*QueueItem
+		for _, id := range test.before {
+			if items[id] == nil {
+				items[id] = testItems(id)[0]
+			}
+			before = append(before, items[id])
+		}
+
+		q.Restore(before, func(item *QueueItem) bool {
+			return containsString(test.played, item.Info.ID)
+		})
+
+		if got := infoIDs(q); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Restore(%v) of %v = %v, want %v", test.before, test.queue, got, test.want)
+		}
+	}
+}
+
+func TestIsItemID(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"ab", true},
+		{"#ab", true},
+		{"AB", true},
+		{"#", false},
+		{"", false},
+		{"2", false},
+		{"a2", false},
+		{"2-4", false},
+		{"ab cd", false},
+	}
+
+	for _, test := range tests {
+		if got := IsItemID(test.ref); got != test.want {
+			t.Errorf("IsItemID(%q) = %v, want %v", test.ref, got, test.want)
+		}
+	}
+}
+
+func TestQueueRemoveRefStale(t *testing.T) {
+	q := testQueue("a", "b", "c")
+
+	err := q.RemoveRef("#zz")
+	if err == nil || err.Error() != "No item with ID zz" {
+		t.Errorf("RemoveRef of a stale ID error = %v, want No item with ID zz", err)
+	}
+	if got := infoIDs(q); len(got) != 3 {
+		t.Errorf("RemoveRef of a stale ID removed something, queue is %v", got)
+	}
+}
+
+func TestQueueMoveRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		to      int
+		want    []string
+		wantErr error
+	}{
+		{"3", 1, []string{"a", "c", "b"}, nil},
+		{"#c", 1, []string{"a", "c", "b"}, nil},
+		{"1", 2, []string{"a", "b", "c"}, ErrCurrentItem},
+		{"a", 2, []string{"a", "b", "c"}, ErrCurrentItem},
+	}
+
+	for _, test := range tests {
+		q := testQueue("a", "b", "c")
+		err := q.MoveRef(test.ref, test.to)
+
+		if err != test.wantErr {
+			t.Errorf("MoveRef(%q, %d) error = %v, want %v", test.ref, test.to, err, test.wantErr)
+		}
+		if got := infoIDs(q); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("MoveRef(%q, %d) = %v, want %v", test.ref, test.to, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	QueuePageSize     = 10
+	QueuePrevEmoji    = "◀"
+	QueueNextEmoji    = "▶"
+	QueueColor        = 0xe67e22
+	QueueMaxTitleSize = 80
+)
+
+// QueueView is a paginated queue listing, pages are switched with reactions
+type QueueView struct {
+	sync.Mutex
+	session   *discordgo.Session
+	channelID string // where the current listing was posted
+	messageID string
+	page      int
+}
+
+func CreateQueueView(session *discordgo.Session) *QueueView {
+	return &QueueView{
+		session: session,
+	}
+}
+
+// Show posts a new listing to the given channel, older one stops reacting to controls
+func (qv *QueueView) Show(player *Player, channelID string) error {
+	qv.Lock()
+	defer qv.Unlock()
+
+	qv.page = 0
+	qv.channelID = channelID
+
+	embed, err := qv.embed(player)
+	if err != nil {
+		return err
+	}
+
+	msg, err := qv.session.ChannelMessageSendEmbed(qv.channelID, embed)
+	if err != nil {
+		return err
+	}
+
+	qv.messageID = msg.ID
+
+	for _, emoji := range []string{QueuePrevEmoji, QueueNextEmoji} {
+		err := qv.session.MessageReactionAdd(qv.channelID, qv.messageID, emoji)
+		if err != nil {
+			logrus.WithError(err).WithField("channel", qv.channelID).Warn("Adding queue control failed")
+		}
+	}
+
+	return nil
+}
+
+func (qv *QueueView) MessageID() string {
+	qv.Lock()
+	defer qv.Unlock()
+
+	return qv.messageID
+}
+
+// Turn moves the listing by the given number of pages
+func (qv *QueueView) Turn(player *Player, pages int) {
+	qv.Lock()
+	defer qv.Unlock()
+
+	qv.page += pages
+
+	embed, err := qv.embed(player)
+	if err != nil {
+		logrus.WithError(err).WithField("channel", qv.channelID).Warn("Building queue listing failed")
+		return
+	}
+
+	_, err = qv.session.ChannelMessageEditEmbed(qv.channelID, qv.messageID, embed)
+	if err != nil {
+		logrus.WithError(err).WithField("channel", qv.channelID).Warn("Updating queue listing failed")
+	}
+}
+
+func (qv *QueueView) embed(player *Player) (*discordgo.MessageEmbed, error) {
+	queue, err := player.Queue.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	pages := (len(queue) + QueuePageSize - 1) / QueuePageSize
+	if qv.page >= pages {
+		qv.page = pages - 1
+	} else if qv.page < 0 {
+		qv.page = 0
+	}
+
+	// time until each song starts, the current one is partially played already
+	var remaining time.Duration
+	starts := make([]time.Duration, len(queue))
+	for i, item := range queue {
+		starts[i] = remaining
+		remaining += item.Info.Duration
+
+		if i == 0 {
+			remaining -= player.CurrentPosition()
+		}
+	}
+
+	var lines []string
+	for i := qv.page * QueuePageSize; i < len(queue) && i < (qv.page+1)*QueuePageSize; i++ {
+		item := queue[i]
+
+		title := item.Info.Title
+		if runes := []rune(title); len(runes) > QueueMaxTitleSize {
+			title = string(runes[:QueueMaxTitleSize]) + "…"
+		}
+
+		start := "in " + FormatDuration(starts[i])
+		if i == 0 {
+			start = "now playing"
+		}
+
+		requester := item.RequestedBy
+		if item.AutoQueued {
+			requester += " [autoplay]"
+		}
+
+		lines = append(lines, fmt.Sprintf("`%d.` `#%s` [%s](%s) `%s`\n%s, %s", i+1, item.ID, title, item.Info.Link, FormatDuration(item.Info.Duration), requester, start))
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       "Queue",
+		Color:       QueueColor,
+		Description: strings.Join(lines, "\n"),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d/%d • %d songs • %s remaining", qv.page+1, pages, len(queue), FormatDuration(remaining)),
+		},
+	}, nil
+}
+
+// ProcessQueueViewReaction switches pages of the queue listing
+func (bot *Bot) ProcessQueueViewReaction(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	s.MessageReactionRemove(r.ChannelID, r.MessageID, r.Emoji.APIName(), r.UserID)
+
+	switch r.Emoji.Name {
+	case QueuePrevEmoji:
+		bot.Player.QueueView.Turn(bot.Player, -1)
+	case QueueNextEmoji:
+		bot.Player.QueueView.Turn(bot.Player, 1)
+	}
+}
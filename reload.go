@@ -0,0 +1,204 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ReloadWatchInterval = 2 * time.Second
+
+// RestartRequired lists config keys which are only read on startup
+var RestartRequired = map[string]bool{
+	"token":          true,
+	"guild":          true,
+	"httpAddress":    true,
+	"ytApiKey":       true,
+	"historySize":    true,
+	"normalize":      true,
+	"targetLoudness": true,
+}
+
+type ReloadReport struct {
+	Applied         []string // config keys, and permissions if they changed
+	RestartRequired []string
+	Unknown         []string // unknown or duplicate keys in the config file
+}
+
+func (report *ReloadReport) Changed() bool {
+	return len(report.Applied) > 0 || len(report.RestartRequired) > 0
+}
+
+func (report *ReloadReport) String() string {
+	text := "Reloaded"
+	if !report.Changed() {
+		text += ", nothing changed"
+	}
+	if len(report.Applied) > 0 {
+		text += "\nApplied: " + strings.Join(report.Applied, ", ")
+	}
+	if len(report.RestartRequired) > 0 {
+		text += "\nNeeds a restart: " + strings.Join(report.RestartRequired, ", ")
+	}
+	if len(report.Unknown) > 0 {
+		text += "\nUnknown keys: " + strings.Join(report.Unknown, ", ")
+	}
+
+	return text
+}
+
+var reloadLock sync.Mutex
+
+// Reload reads the config and permissions again, nothing is changed if either of them is invalid
+func (bot *Bot) Reload() (*ReloadReport, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	config := &Configuration{}
+	unknown, err := config.Load(bot.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := LoadPermissions(PermissionsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReloadReport{Unknown: unknown}
+
+	// settings needing a restart keep their old values, so the running state stays consistent
+	old := reflect.ValueOf(bot.Config()).Elem()
+	updated := reflect.ValueOf(config).Elem()
+	for i := 0; i < old.NumField(); i++ {
+		if reflect.DeepEqual(old.Field(i).Interface(), updated.Field(i).Interface()) {
+			continue
+		}
+
+		key := strings.Split(old.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if RestartRequired[key] {
+			report.RestartRequired = append(report.RestartRequired, key)
+			updated.Field(i).Set(old.Field(i))
+		} else {
+			report.Applied = append(report.Applied, key)
+		}
+	}
+
+	if bot.Permissions.Replace(permissions) {
+		report.Applied = append(report.Applied, "permissions")
+	}
+
+	if len(report.Applied) > 0 {
+		bot.applyConfig(config, containsString(report.Applied, "autoplay"))
+	}
+
+	return report, nil
+}
+
+// applyConfig swaps the config, the player picks up new encode options with the next song or seek,
+// the now playing message moves to a new text channel right away
+func (bot *Bot) applyConfig(config *Configuration, autoplayChanged bool) {
+	err := InitLogging(config.LogLevel, config.LogFormat)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid logging settings")
+	}
+
+	bot.setConfig(config)
+
+	if player := bot.Player; player != nil {
+		player.applyConfig(config, autoplayChanged)
+		player.NowPlaying.SetChannel(config.TextChannel)
+	}
+}
+
+// applyConfig updates settings of a running player, autoplay toggled by a command is kept unless the config changed it
+func (player *Player) applyConfig(config *Configuration, autoplayChanged bool) {
+	player.settingsLock.Lock()
+	defer player.settingsLock.Unlock()
+
+	player.EncodingSettings = &config.EncodeOptions
+	player.Limits = config.Limits
+	player.TextChannel = config.TextChannel
+
+	if autoplayChanged {
+		player.Autoplay = config.Autoplay
+	}
+}
+
+// ReloadAndReport reloads and logs the outcome
+func (bot *Bot) ReloadAndReport(reason string) (*ReloadReport, error) {
+	entry := logrus.WithField("reason", reason)
+
+	report, err := bot.Reload()
+	if err != nil {
+		entry.WithError(err).Error("Reload failed, keeping the old config")
+		return nil, err
+	}
+
+	if report.Changed() {
+		entry.WithFields(logrus.Fields{
+			"applied":         report.Applied,
+			"restartRequired": report.RestartRequired,
+		}).Info("Reloaded")
+	}
+
+	for _, key := range report.Unknown {
+		entry.Warn("Unknown or duplicate config key, " + key)
+	}
+
+	return report, nil
+}
+
+// WatchConfig reloads when the config or the permissions file is modified, polling works with editors replacing files too
+func (bot *Bot) WatchConfig() {
+	modified := func() time.Time {
+		var latest time.Time
+		for _, path := range []string{bot.ConfigPath, PermissionsFile} {
+			if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+				latest = info.ModTime()
+			}
+		}
+
+		return latest
+	}
+
+	last := modified()
+
+	for range time.Tick(ReloadWatchInterval) {
+		if current := modified(); current != last {
+			last = current
+			bot.ReloadAndReport("file changed")
+		}
+	}
+}
+
+func (cmds *Commands) InitReload() {
+	reload := CommandConstructor{
+		Names:             []string{"reload"},
+		Permission:        "reload",
+		DefaultPermission: false,
+		NoArguments:       true,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			report, err := bot.ReloadAndReport("command")
+			if err != nil {
+				return err
+			}
+
+			s.ChannelMessageSend(m.ChannelID, report.String())
+			return nil
+		},
+	}
+
+	cmds.RegisterCommands(&reload)
+}
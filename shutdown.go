@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	ShutdownTimeout = 10 * time.Second
+	QueueFile       = "queue.json" // queue left on shutdown, restored when the player joins again
+)
+
+type SavedQueueItem struct {
+	SavedItem
+	RequestedBy string
+	RequesterID string
+}
+
+// Shutdown stops the player, the HTTP server and the discord session, the queue is saved even if the player doesn't stop in time
+func (bot *Bot) Shutdown(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if player := bot.Player; player != nil {
+		current, _ := player.Queue.GetFirst()
+
+		// stopping purges the queue
+		queue, _ := player.Queue.GetAll()
+		err := SaveQueue(QueueFile, queue)
+		if err != nil {
+			logrus.WithError(err).Error("Saving queue failed")
+		}
+
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- bot.StopPlayer()
+		}()
+
+		select {
+		case err := <-stopped:
+			if err != nil {
+				player.log().WithError(err).Warn("Stopping player failed")
+			}
+		case <-ctx.Done():
+			player.log().Warn("Player didn't stop in time")
+
+			// at least don't leave youtube-dl running
+			if current != nil {
+				current.Stream.Stop()
+			}
+		}
+	}
+
+	if bot.HTTPServer != nil {
+		err := bot.HTTPServer.Shutdown(ctx)
+		if err != nil {
+			logrus.WithError(err).Warn("Stopping HTTP server failed")
+		}
+	}
+
+	// stores save on every change, saving them again here could overwrite files they failed to load
+	err := bot.DiscordSession.Close()
+	if err != nil {
+		logrus.WithError(err).Warn("Closing discord session failed")
+	}
+}
+
+// SaveQueue stores links of the queued songs, the current one is restored from its beginning
+func SaveQueue(filePath string, queue []*QueueItem) error {
+	if len(queue) == 0 {
+		return nil
+	}
+
+	saved := make([]SavedQueueItem, len(queue))
+	for i, item := range queue {
+		saved[i] = SavedQueueItem{
+			SavedItem: SavedItem{
+				Link:     item.Info.Link,
+				Title:    item.Info.Title,
+				Duration: item.Info.Duration,
+			},
+			RequestedBy: item.RequestedBy,
+			RequesterID: item.RequesterID,
+		}
+	}
+
+	text, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, text, 0644)
+}
+
+// RestoreQueue adds the queue saved on shutdown to the player, the file is removed so it's restored only once
+func (bot *Bot) RestoreQueue(filePath string) {
+	file, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		logrus.WithError(err).Error("Loading saved queue failed")
+		return
+	}
+
+	err = os.Remove(filePath)
+	if err != nil {
+		logrus.WithError(err).Warn("Removing saved queue failed")
+	}
+
+	var saved []SavedQueueItem
+	err = json.Unmarshal(file, &saved)
+	if err != nil {
+		logrus.WithError(err).Error("Loading saved queue failed")
+		return
+	}
+
+	for _, entry := range saved {
+		player := bot.Player
+		if player == nil {
+			return
+		}
+
+		requested := &discordgo.User{ID: entry.RequesterID, Username: entry.RequestedBy}
+		item, err := CreateQueueItem(entry.Link, requested, bot.Blocklist)
+		if err != nil {
+			logrus.WithError(err).WithField("link", entry.Link).Warn("Restoring queue item failed")
+			continue
+		}
+
+		err = player.Add(item)
+		if err != nil {
+			logrus.WithError(err).WithField("link", entry.Link).Warn("Restoring queue item failed")
+		}
+	}
+}
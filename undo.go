@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"sync"
+	"time"
+)
+
+const UndoLogSize = 20
+
+type UndoEntry struct {
+	Operation  string    // name of the command
+	Permission string    // needed to undo the operation
+	Time       time.Time // when the queue state was taken
+	queue      []*QueueItem
+}
+
+// UndoLog keeps queue states from before recent changes, newest last
+type UndoLog struct {
+	sync.Mutex
+	size    int
+	entries []UndoEntry
+}
+
+func CreateUndoLog(size int) *UndoLog {
+	return &UndoLog{
+		size: size,
+	}
+}
+
+// Record stores the queue state from before the command, if the command changed the queue,
+// taken is when the state was read, songs played since then aren't restored
+func (ul *UndoLog) Record(cmd *CommandConstructor, before []*QueueItem, taken time.Time, queue *Queue) {
+	after, _ := queue.GetAll()
+
+	changed := len(before) != len(after)
+	for i := 0; !changed && i < len(before); i++ {
+		changed = before[i] != after[i]
+	}
+
+	if !changed {
+		return
+	}
+
+	ul.Lock()
+	defer ul.Unlock()
+
+	ul.entries = append(ul.entries, UndoEntry{
+		Operation:  cmd.Names[0],
+		Permission: cmd.Permission,
+		Time:       taken,
+		queue:      before,
+	})
+
+	if len(ul.entries) > ul.size {
+		ul.entries[0] = UndoEntry{}
+		ul.entries = ul.entries[1:]
+	}
+}
+
+func (ul *UndoLog) Last() (UndoEntry, error) {
+	ul.Lock()
+	defer ul.Unlock()
+
+	if len(ul.entries) == 0 {
+		return UndoEntry{}, errors.New("Nothing to undo")
+	}
+
+	return ul.entries[len(ul.entries)-1], nil
+}
+
+// Pop removes the given entry if it's still the last one
+func (ul *UndoLog) Pop(entry UndoEntry) bool {
+	ul.Lock()
+	defer ul.Unlock()
+
+	if len(ul.entries) == 0 || ul.entries[len(ul.entries)-1].Time != entry.Time {
+		return false
+	}
+
+	ul.entries[len(ul.entries)-1] = UndoEntry{}
+	ul.entries = ul.entries[:len(ul.entries)-1]
+
+	return true
+}
+
+func (cmds *Commands) InitUndo() {
+	undo := CommandConstructor{
+		Names:             []string{"undo", "u"},
+		Permission:        "undo",
+		DefaultPermission: true,
+		NoArguments:       true,
+		MinArguments:      0,
+		MaxArguments:      -1,
+		RunFunc: func(bot *Bot, raw []string, m *discordgo.MessageCreate, s *discordgo.Session) error {
+			if bot.Player == nil {
+				return ErrPlayerNotConnected
+			}
+
+			entry, err := bot.Player.Undo.Last()
+			if err != nil {
+				return err
+			}
+
+			// undoing needs the same permission as the operation itself
+			if !bot.HasPermission(m.Author.ID, bot.Commands.ByPermission[entry.Permission]) {
+				PermissionDenials.WithLabelValues(entry.Permission).Inc()
+				return errors.New("Permission denied to undo " + entry.Operation)
+			}
+
+			if !bot.Player.Undo.Pop(entry) {
+				return errors.New("Queue changed in the meantime, try again")
+			}
+
+			bot.Player.Restore(entry.queue, func(item *QueueItem) bool {
+				return bot.Player.History.PlayedSince(item, entry.Time)
+			})
+
+			s.ChannelMessageSend(m.ChannelID, "Undone "+entry.Operation)
+			return nil
+		},
+	}
+
+	cmds.RegisterCommands(&undo)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUndoLogRecord(t *testing.T) {
+	cmd := &CommandConstructor{Names: []string{"remove"}, Permission: "remove"}
+	q := testQueue("a", "b", "c", "d")
+	ul := CreateUndoLog(2)
+
+	before, _ := q.GetAll()
+	taken := time.Now().Add(-time.Minute) // a slow command
+	ul.Record(cmd, before, taken, q)
+	if _, err := ul.Last(); err == nil {
+		t.Error("Record() stored an entry for an unchanged queue")
+	}
+
+	for i := 0; i < 3; i++ {
+		before, _ := q.GetAll()
+		q.Remove(1)
+		ul.Record(cmd, before, taken.Add(time.Duration(i)*time.Second), q)
+	}
+
+	entry, err := ul.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := taken.Add(2 * time.Second); !entry.Time.Equal(want) {
+		t.Errorf("Last().Time = %v, want the time the state was taken %v", entry.Time, want)
+	}
+	if len(entry.queue) != 2 {
+		t.Errorf("Last() has %d songs, want 2", len(entry.queue))
+	}
+
+	if !ul.Pop(entry) || ul.Pop(entry) {
+		t.Error("Pop() should remove the entry only once")
+	}
+
+	// the oldest entry was dropped over the size
+	entry, err = ul.Last()
+	if err != nil || !ul.Pop(entry) {
+		t.Fatalf("Last() = %v, want one more entry", err)
+	}
+	if _, err := ul.Last(); err == nil {
+		t.Error("Last() returned an entry over the log size")
+	}
+}
@@ -3,18 +3,21 @@ package main
 import (
 	"bufio"
 	"errors"
-	"fmt"
+	"github.com/bwmarrin/discordgo"
 	"github.com/rylio/ytdl"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2/google"
 	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/youtube/v3"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
+	"regexp"
 )
 
+var YoutubeVideoRegexp = regexp.MustCompile(`youtu(?:be\.com/(?:v/|e(?:mbed)?/|watch\?v=)|\.be/)([\w-]{11}\b)`)
+
 type YoutubeItem struct {
 	Video   *ytdl.VideoInfo
 	ytdlCmd *exec.Cmd
@@ -51,30 +54,49 @@ func (yt *YoutubeItem) Play() io.Reader {
 	yt.ytdlCmd.Stderr = os.Stderr
 	youtubedlOut, err := yt.ytdlCmd.StdoutPipe()
 	if err != nil {
-		fmt.Println("YTDL StdoutPipe Error:", err)
+		logrus.WithError(err).WithField("video", yt.Video.ID).Error("Starting youtube-dl failed")
+		YoutubeDLFailures.Inc()
 		return nil
 	}
 
 	err = yt.ytdlCmd.Start()
 	if err != nil {
-		fmt.Println("YTDL StdoutPipe Error:", err)
+		logrus.WithError(err).WithField("video", yt.Video.ID).Error("Starting youtube-dl failed")
+		YoutubeDLFailures.Inc()
 		return nil
 	}
 	return bufio.NewReaderSize(youtubedlOut, 65536)
 }
 
+// Stop kills youtube-dl if it's still running and collects its exit status
 func (yt *YoutubeItem) Stop() {
-	if yt.ytdlCmd.Process != nil {
-		yt.ytdlCmd.Process.Kill()
+	if yt.ytdlCmd == nil || yt.ytdlCmd.Process == nil || yt.ytdlCmd.ProcessState != nil {
+		return
+	}
+
+	yt.ytdlCmd.Process.Kill()
+
+	// killed processes have no exit code, a positive one means youtube-dl failed on its own before
+	err := yt.ytdlCmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+		logrus.WithError(err).WithField("video", yt.Video.ID).Warn("youtube-dl failed")
+		YoutubeDLFailures.Inc()
 	}
 }
 
 func (yt *YoutubeItem) GetInfo() ItemInfo {
-	return ItemInfo{
+	info := ItemInfo{
+		ID:       "youtube:" + yt.Video.ID,
 		Title:    yt.Video.Title,
 		Link:     "http://youtu.be/" + yt.Video.ID,
-		Duration: yt.Video.Duration.String(),
+		Duration: yt.Video.Duration,
+	}
+
+	if thumbnail := yt.Video.GetThumbnailURL(ytdl.ThumbnailQualityHigh); thumbnail != nil {
+		info.Thumbnail = thumbnail.String()
 	}
+
+	return info
 }
 
 func CreateYoutubeItem(url string) (*YoutubeItem, error) {
@@ -86,20 +108,26 @@ func CreateYoutubeItem(url string) (*YoutubeItem, error) {
 	return &YoutubeItem{video, nil}, nil
 }
 
-func CreateQueueItem(url, requested string) (*QueueItem, error) {
+func CreateQueueItem(url string, requested *discordgo.User, blocklist *Blocklist) (*QueueItem, error) {
 	video, err := CreateYoutubeItem(url)
 	if err != nil {
 		return nil, err
 	}
 
+	err = blocklist.CheckYoutube(video)
+	if err != nil {
+		return nil, err
+	}
+
 	return &QueueItem{
 		Stream:      video,
 		Info:        video.GetInfo(),
-		RequestedBy: requested,
+		RequestedBy: requested.Username,
+		RequesterID: requested.ID,
 	}, nil
 }
 
-func RetrievePlaylist(service *youtube.Service, url string, requested string, items chan *QueueItem) error {
+func RetrievePlaylist(service *youtube.Service, url string, requested *discordgo.User, blocklist *Blocklist, items chan *QueueItem) error {
 	playlistItems, err := service.PlaylistItems.List("snippet").PlaylistId(url).MaxResults(50).Do()
 	if err != nil {
 		return err
@@ -109,9 +137,9 @@ func RetrievePlaylist(service *youtube.Service, url string, requested string, it
 		defer close(items)
 
 		for _, video := range playlistItems.Items {
-			item, err := CreateQueueItem(video.Snippet.ResourceId.VideoId, requested)
+			item, err := CreateQueueItem(video.Snippet.ResourceId.VideoId, requested, blocklist)
 			if err != nil {
-				log.Println(err)
+				logrus.WithError(err).WithField("video", video.Snippet.ResourceId.VideoId).Warn("Skipping playlist item")
 				continue
 			}
 
@@ -122,7 +150,7 @@ func RetrievePlaylist(service *youtube.Service, url string, requested string, it
 	return nil
 }
 
-func Find(service *youtube.Service, query string, requested string) (*QueueItem, error) {
+func Find(service *youtube.Service, query string, requested *discordgo.User, blocklist *Blocklist) (*QueueItem, error) {
 	videos, err := service.Search.List("snippet").Q(query).Type("video").MaxResults(1).Do()
 	if err != nil {
 		return nil, err
@@ -132,7 +160,7 @@ func Find(service *youtube.Service, query string, requested string) (*QueueItem,
 		return nil, errors.New("No video found")
 	}
 
-	item, err := CreateQueueItem(videos.Items[0].Id.VideoId, requested)
+	item, err := CreateQueueItem(videos.Items[0].Id.VideoId, requested, blocklist)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +168,30 @@ func Find(service *youtube.Service, query string, requested string) (*QueueItem,
 	return item, nil
 }
 
+// RelatedVideo returns the first video related to the given one which isn't excluded, e.g. because it was played recently
+func RelatedVideo(service *youtube.Service, videoID string, requested *discordgo.User, blocklist *Blocklist, exclude func(id string) bool) (*QueueItem, error) {
+	videos, err := service.Search.List("snippet").RelatedToVideoId(videoID).Type("video").MaxResults(10).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, video := range videos.Items {
+		if exclude("youtube:" + video.Id.VideoId) {
+			continue
+		}
+
+		item, err := CreateQueueItem(video.Id.VideoId, requested, blocklist)
+		if err != nil {
+			logrus.WithError(err).WithField("video", video.Id.VideoId).Warn("Skipping related video")
+			continue
+		}
+
+		return item, nil
+	}
+
+	return nil, errors.New("No related video found")
+}
+
 func LoadYoutubeAPIConfig(filePath string) (*jwt.Config, error) {
 	token, err := ioutil.ReadFile(filePath)
 	if err != nil {